@@ -1,32 +1,165 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/KishorPokharel/kanban/mailer"
+	"github.com/KishorPokharel/kanban/oidc"
 	"github.com/KishorPokharel/kanban/postgres"
 	"github.com/julienschmidt/httprouter"
 )
 
+type config struct {
+	// readOnlyUnverified limits users who have not verified their email
+	// address to reading their boards.
+	readOnlyUnverified bool
+	// emailLockout and ipLockout throttle failed logins per account and per
+	// client IP.
+	emailLockout postgres.LockoutPolicy
+	ipLockout    postgres.LockoutPolicy
+}
+
 type application struct {
+	config  config
 	logger  *log.Logger
 	service postgres.Service
+	mailer  mailer.Mailer
+	// sso is the OpenID Connect provider users can log in with, or nil when
+	// single sign-on is not configured.
+	sso *oidc.Provider
+	// wg tracks background work and jobs that shutdown waits for.
+	wg sync.WaitGroup
 }
 
 func (app *application) routes() http.Handler {
 	router := httprouter.New()
 
 	router.HandlerFunc(http.MethodPost, "/api/users/register", app.handleUserRegister)
+	router.HandlerFunc(http.MethodPost, "/api/users/activate", app.handleUserActivate)
+	router.HandlerFunc(http.MethodPost, "/api/users/activation", app.handleUserActivationResend)
 	router.HandlerFunc(http.MethodPost, "/api/users/login", app.handleUserLogin)
+	router.HandlerFunc(http.MethodPost, "/api/users/login/2fa", app.handleUserLoginTwoFactor)
+	router.HandlerFunc(http.MethodPost, "/api/users/refresh", app.handleUserRefresh)
+	if app.sso != nil {
+		router.HandlerFunc(http.MethodGet, "/api/auth/oidc/login", app.handleOIDCLogin)
+		router.HandlerFunc(http.MethodGet, "/api/auth/oidc/callback", app.handleOIDCCallback)
+	}
+	router.HandlerFunc(http.MethodPost, "/api/users/password/forgot", app.handleUserPasswordForgot)
+	router.HandlerFunc(http.MethodPost, "/api/users/password/reset", app.handleUserPasswordReset)
+	router.HandlerFunc(http.MethodPut, "/api/users/me/password", app.authenticate(app.handleUserPasswordChange))
+	router.HandlerFunc(http.MethodPut, "/api/users/me/email", app.authenticate(app.handleUserEmailChange))
+	router.HandlerFunc(http.MethodDelete, "/api/users/me", app.authenticate(app.handleUserDelete))
+	router.HandlerFunc(http.MethodGet, "/api/users/me/export", app.authenticate(app.handleUserExport))
+	router.HandlerFunc(http.MethodPost, "/api/users/me/2fa", app.authenticate(app.handleTwoFactorSetUp))
+	router.HandlerFunc(http.MethodPost, "/api/users/me/2fa/confirm", app.authenticate(app.handleTwoFactorConfirm))
+	router.HandlerFunc(http.MethodDelete, "/api/users/me/2fa", app.authenticate(app.handleTwoFactorDisable))
+	router.HandlerFunc(http.MethodPost, "/api/users/logout", app.authenticate(app.handleUserLogout))
+	router.HandlerFunc(http.MethodPost, "/api/users/logout-all", app.authenticate(app.handleUserLogoutAll))
+	router.HandlerFunc(http.MethodGet, "/api/users/sessions", app.authenticate(app.handleUserSessionsGet))
+	router.HandlerFunc(http.MethodDelete, "/api/users/sessions/:session_id", app.authenticate(app.handleUserSessionDelete))
+	router.HandlerFunc(http.MethodGet, "/api/users/tokens", app.authenticate(app.handleUserTokensGet))
+	router.HandlerFunc(http.MethodPost, "/api/users/tokens", app.authenticate(app.handleUserTokenCreate))
+	router.HandlerFunc(http.MethodDelete, "/api/users/tokens/:token_id", app.authenticate(app.handleUserTokenDelete))
+
+	router.HandlerFunc(http.MethodGet, "/api/orgs", app.requireScope(postgres.ScopeTasksRead, app.handleOrgsGet))
+	router.HandlerFunc(http.MethodPost, "/api/orgs", app.requireScope(postgres.ScopeBoardsAdmin, app.handleOrgCreate))
+	router.HandlerFunc(http.MethodGet, "/api/orgs/:id", app.requireScope(postgres.ScopeTasksRead, app.requireOrg(app.handleOrgGet)))
+	router.HandlerFunc(http.MethodDelete, "/api/orgs/:id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleOwner, app.handleOrgDelete))))
+	router.HandlerFunc(http.MethodGet, "/api/orgs/:id/members", app.requireScope(postgres.ScopeTasksRead, app.requireOrg(app.handleOrgMembersGet)))
+	router.HandlerFunc(http.MethodPost, "/api/orgs/:id/members", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.handleOrgMemberAdd))))
+	router.HandlerFunc(http.MethodPatch, "/api/orgs/:id/members/:user_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.handleOrgMemberUpdate))))
+	router.HandlerFunc(http.MethodDelete, "/api/orgs/:id/members/:user_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.handleOrgMemberDelete)))
+	router.HandlerFunc(http.MethodGet, "/api/orgs/:id/boards", app.requireScope(postgres.ScopeTasksRead, app.requireOrg(app.handleOrgBoardsGet)))
+	router.HandlerFunc(http.MethodPost, "/api/orgs/:id/boards", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.handleOrgBoardCreate))))
+	router.HandlerFunc(http.MethodGet, "/api/orgs/:id/teams", app.requireScope(postgres.ScopeTasksRead, app.requireOrg(app.handleTeamsGet)))
+	router.HandlerFunc(http.MethodPost, "/api/orgs/:id/teams", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.handleTeamCreate))))
+	router.HandlerFunc(http.MethodGet, "/api/orgs/:id/teams/:team_id", app.requireScope(postgres.ScopeTasksRead, app.requireOrg(app.requireTeam(app.handleTeamGet))))
+	router.HandlerFunc(http.MethodDelete, "/api/orgs/:id/teams/:team_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.requireTeam(app.handleTeamDelete)))))
+	router.HandlerFunc(http.MethodPut, "/api/orgs/:id/teams/:team_id/members/:user_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.requireTeam(app.handleTeamMemberAdd)))))
+	router.HandlerFunc(http.MethodDelete, "/api/orgs/:id/teams/:team_id/members/:user_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.requireTeam(app.handleTeamMemberDelete)))))
+	router.HandlerFunc(http.MethodPut, "/api/orgs/:id/teams/:team_id/boards/:board_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.requireTeam(app.handleTeamBoardGrant)))))
+	router.HandlerFunc(http.MethodDelete, "/api/orgs/:id/teams/:team_id/boards/:board_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireOrg(app.requireOrgRole(postgres.OrgRoleAdmin, app.requireTeam(app.handleTeamBoardRevoke)))))
+
+	router.HandlerFunc(http.MethodGet, "/api/boards", app.requireScope(postgres.ScopeTasksRead, app.handleBoardsGet))
+	router.HandlerFunc(http.MethodPost, "/api/boards", app.requireScope(postgres.ScopeBoardsAdmin, app.handleBoardCreate))
+	router.HandlerFunc(http.MethodGet, "/api/boards/:id", app.requireScope(postgres.ScopeTasksRead, app.requireBoard(app.handleBoardGet)))
+	router.HandlerFunc(http.MethodPatch, "/api/boards/:id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.requireIfMatch(app.handleBoardUpdate)))))
+	router.HandlerFunc(http.MethodDelete, "/api/boards/:id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.requireIfMatch(app.handleBoardDelete)))))
+
+	router.HandlerFunc(http.MethodGet, "/api/boards/:id/members", app.requireScope(postgres.ScopeTasksRead, app.requireBoard(app.handleMembersGet)))
+	router.HandlerFunc(http.MethodPost, "/api/boards/:id/members", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.handleMemberAdd))))
+	router.HandlerFunc(http.MethodPatch, "/api/boards/:id/members/:user_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.handleMemberUpdate))))
+	router.HandlerFunc(http.MethodDelete, "/api/boards/:id/members/:user_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.handleMemberDelete)))
+
+	router.HandlerFunc(http.MethodGet, "/api/boards/:id/invitations", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.handleInvitationsGet))))
+	router.HandlerFunc(http.MethodPost, "/api/boards/:id/invitations", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.handleInvitationCreate))))
+	router.HandlerFunc(http.MethodDelete, "/api/boards/:id/invitations/:invitation_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleOwner, app.handleInvitationDelete))))
+	router.HandlerFunc(http.MethodPost, "/api/invitations/accept", app.authenticate(app.handleInvitationAccept))
 
-	router.HandlerFunc(http.MethodGet, "/api/tasks", app.authenticate(app.handleTasksGet))
-	router.HandlerFunc(http.MethodPost, "/api/tasks", app.authenticate(app.handleTaskCreate))
-	router.HandlerFunc(http.MethodPost, "/api/tasks/sort", app.authenticate(app.handleTaskSort))
+	router.HandlerFunc(http.MethodGet, "/api/boards/:id/columns", app.requireScope(postgres.ScopeTasksRead, app.requireBoard(app.handleColumnsGet)))
+	router.HandlerFunc(http.MethodPost, "/api/boards/:id/columns", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleColumnCreate)))))
+	router.HandlerFunc(http.MethodPost, "/api/boards/:id/columns/sort", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleColumnSort)))))
+	router.HandlerFunc(http.MethodPatch, "/api/boards/:id/columns/:column_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleColumnUpdate)))))
+	router.HandlerFunc(http.MethodDelete, "/api/boards/:id/columns/:column_id", app.requireScope(postgres.ScopeBoardsAdmin, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleColumnDelete)))))
+
+	router.HandlerFunc(http.MethodGet, "/api/boards/:id/tasks", app.requireScope(postgres.ScopeTasksRead, app.requireBoard(app.handleTasksGet)))
+	router.HandlerFunc(http.MethodGet, "/api/boards/:id/tasks/archived", app.requireScope(postgres.ScopeTasksRead, app.requireBoard(app.handleTasksArchivedGet)))
+	router.HandlerFunc(http.MethodPost, "/api/boards/:id/tasks", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskCreate)))))
+	router.HandlerFunc(http.MethodPost, "/api/boards/:id/tasks/sort", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskSort)))))
+	router.HandlerFunc(http.MethodPatch, "/api/boards/:id/tasks/:task_id", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskUpdate)))))
+	router.HandlerFunc(http.MethodDelete, "/api/boards/:id/tasks/:task_id", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskDelete)))))
+	router.HandlerFunc(http.MethodPut, "/api/boards/:id/tasks/:task_id/assignees", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskAssigneesSet)))))
+	router.HandlerFunc(http.MethodPut, "/api/boards/:id/tasks/:task_id/archive", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskArchive)))))
+	router.HandlerFunc(http.MethodDelete, "/api/boards/:id/tasks/:task_id/archive", app.requireScope(postgres.ScopeTasksWrite, app.requireBoard(app.requireRole(postgres.RoleEditor, app.requireIfMatch(app.handleTaskUnarchive)))))
 
 	return app.logRequest(app.enableCors(router))
 }
 
-func (app *application) run() error {
-	app.logger.Println("app running")
-	return http.ListenAndServe(":3000", app.routes())
+// background runs fn in a new goroutine, logging instead of crashing the
+// server if it panics. run waits for it before returning.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Println(fmt.Errorf("background task panicked: %v", err))
+			}
+		}()
+		fn()
+	}()
+}
+
+// run serves the API until ctx is cancelled, then stops accepting requests,
+// lets the ones in flight finish and waits for background work and jobs.
+func (app *application) run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    ":3000",
+		Handler: app.routes(),
+	}
+	errs := make(chan error, 1)
+	go func() {
+		app.logger.Println("app running")
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	app.logger.Println("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	// Background jobs are waited for even if connections did not drain in
+	// time, so none is cut off halfway.
+	err := srv.Shutdown(shutdownCtx)
+	app.wg.Wait()
+	return err
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func (app *application) handleBoardsGet(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	boards, err := app.service.Board.GetAll(user.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"boards": boards,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleBoardCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 100)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	board := &postgres.Board{
+		UserID: user.ID,
+		Name:   input.Name,
+	}
+	if err := app.service.Board.Create(board); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Board created successfully",
+		"data": map[string]any{
+			"board": board,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleBoardGet(w http.ResponseWriter, r *http.Request) {
+	board := app.contextGetBoard(r)
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"board": board,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleBoardUpdate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 100)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	board.Name = input.Name
+	if err := app.service.Board.Update(board); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrBoardNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Board updated successfully",
+		"data": map[string]any{
+			"board": board,
+		},
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleBoardDelete(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	board := app.contextGetBoard(r)
+	if err := app.service.Board.Delete(user.ID, board); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrBoardNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Board deleted successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+var colorRX = regexp.MustCompile("^#[0-9a-fA-F]{6}$")
+
+func (app *application) handleColumnsGet(w http.ResponseWriter, r *http.Request) {
+	board := app.contextGetBoard(r)
+	columns, err := app.service.Column.GetAll(board.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"columns": columns,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleColumnCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name     string `json:"name"`
+		Color    string `json:"color"`
+		WIPLimit int    `json:"wip_limit"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 50)),
+		validator.Field(&input.Color, validator.Required, validator.Match(colorRX)),
+		validator.Field(&input.WIPLimit, validator.Min(0)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if input.WIPLimit != 0 && board.Role != postgres.RoleOwner {
+		app.forbidWIPLimitResponse(w)
+		return
+	}
+	column := &postgres.Column{
+		BoardID:  board.ID,
+		Name:     input.Name,
+		Color:    input.Color,
+		WIPLimit: input.WIPLimit,
+	}
+	if err := app.service.Column.Create(board, column); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Column created successfully",
+		"data": map[string]any{
+			"column": column,
+		},
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleColumnUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "column_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+		return
+	}
+	input := struct {
+		Name     *string `json:"name"`
+		Color    *string `json:"color"`
+		WIPLimit *int    `json:"wip_limit"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.NilOrNotEmpty, validator.Length(1, 50)),
+		validator.Field(&input.Color, validator.NilOrNotEmpty, validator.Match(colorRX)),
+		validator.Field(&input.WIPLimit, validator.Min(0)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	column, err := app.service.Column.Get(board.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrColumnNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	if input.Name != nil {
+		column.Name = *input.Name
+	}
+	if input.Color != nil {
+		column.Color = *input.Color
+	}
+	if input.WIPLimit != nil && *input.WIPLimit != column.WIPLimit {
+		if board.Role != postgres.RoleOwner {
+			app.forbidWIPLimitResponse(w)
+			return
+		}
+		column.WIPLimit = *input.WIPLimit
+	}
+	if err := app.service.Column.Update(board, column); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrColumnNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Column updated successfully",
+		"data": map[string]any{
+			"column": column,
+		},
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleColumnSort(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		ColumnID         int64 `json:"column_id"`
+		DestinationIndex int64 `json:"destination_index"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.ColumnID, validator.Required, validator.Min(1)),
+		validator.Field(&input.DestinationIndex, validator.Min(0)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if err := app.service.Column.Move(board, input.ColumnID, input.DestinationIndex); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrColumnNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+			return
+		case errors.Is(err, postgres.ErrInvalidData):
+			app.errorResponse(w, http.StatusBadRequest, "invalid data", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleColumnDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "column_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if err := app.service.Column.Delete(board, id); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrColumnNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+			return
+		case errors.Is(err, postgres.ErrColumnNotEmpty):
+			app.errorResponse(w, http.StatusConflict, "Column still has tasks", err)
+			return
+		case errors.Is(err, postgres.ErrLastColumn):
+			app.errorResponse(w, http.StatusConflict, "A board needs at least one column", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Column deleted successfully",
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// forbidWIPLimitResponse refuses a WIP limit change by someone other than a
+// board owner. Editors could otherwise lift a limit and then work past it,
+// which only owners may do.
+func (app *application) forbidWIPLimitResponse(w http.ResponseWriter) {
+	app.errorResponse(
+		w,
+		http.StatusForbidden,
+		"Only the board owner can change WIP limits",
+		errors.New("wip limit change by non owner"),
+	)
+}
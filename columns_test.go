@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KishorPokharel/kanban/postgres"
+)
+
+func TestColumnCreateWIPLimitNeedsOwner(t *testing.T) {
+	app := &application{logger: log.New(io.Discard, "", 0)}
+	body := `{"name": "Review", "color": "#aabbcc", "wip_limit": 3}`
+	r := httptest.NewRequest(http.MethodPost, "/api/boards/1/columns", strings.NewReader(body))
+	r = app.contextSetBoard(r, &postgres.Board{ID: 1, Role: postgres.RoleEditor})
+	w := httptest.NewRecorder()
+
+	app.handleColumnCreate(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("editor setting a WIP limit: want %d; got %d", http.StatusForbidden, w.Code)
+	}
+}
@@ -9,7 +9,13 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey  = contextKey("user")
+	boardContextKey = contextKey("board")
+	tokenContextKey = contextKey("token")
+	orgContextKey   = contextKey("org")
+	teamContextKey  = contextKey("team")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *postgres.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -23,3 +29,55 @@ func (app *application) contextGetUser(r *http.Request) *postgres.User {
 	}
 	return user
 }
+
+func (app *application) contextSetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetToken(r *http.Request) string {
+	token, ok := r.Context().Value(tokenContextKey).(string)
+	if !ok {
+		panic("missing token value in request context")
+	}
+	return token
+}
+
+func (app *application) contextSetBoard(r *http.Request, board *postgres.Board) *http.Request {
+	ctx := context.WithValue(r.Context(), boardContextKey, board)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetBoard(r *http.Request) *postgres.Board {
+	board, ok := r.Context().Value(boardContextKey).(*postgres.Board)
+	if !ok {
+		panic("missing board value in request context")
+	}
+	return board
+}
+
+func (app *application) contextSetOrg(r *http.Request, org *postgres.Org) *http.Request {
+	ctx := context.WithValue(r.Context(), orgContextKey, org)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetOrg(r *http.Request) *postgres.Org {
+	org, ok := r.Context().Value(orgContextKey).(*postgres.Org)
+	if !ok {
+		panic("missing org value in request context")
+	}
+	return org
+}
+
+func (app *application) contextSetTeam(r *http.Request, team *postgres.Team) *http.Request {
+	ctx := context.WithValue(r.Context(), teamContextKey, team)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetTeam(r *http.Request) *postgres.Team {
+	team, ok := r.Context().Value(teamContextKey).(*postgres.Team)
+	if !ok {
+		panic("missing team value in request context")
+	}
+	return team
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+const invitationTTL = 7 * 24 * time.Hour
+
+func (app *application) handleInvitationsGet(w http.ResponseWriter, r *http.Request) {
+	board := app.contextGetBoard(r)
+	invitations, err := app.service.Invitation.GetAll(board.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"invitations": invitations,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// handleInvitationCreate mails an invitation to join the board. The token
+// in the mail is accepted through handleInvitationAccept once the invitee
+// is logged in, or when registering through handleUserRegister.
+func (app *application) handleInvitationCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Email, validator.Required, is.Email),
+		validator.Field(&input.Role, validator.Required, validator.In(memberRoles...)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	board := app.contextGetBoard(r)
+	invitation := &postgres.Invitation{
+		BoardID:   board.ID,
+		Email:     input.Email,
+		Role:      input.Role,
+		InvitedBy: user.ID,
+	}
+	if err := app.service.Invitation.New(invitation, invitationTTL); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrAlreadyMember):
+			app.errorResponse(w, http.StatusConflict, "User is already a member of the board", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi,\n\n"+
+				"%s invited you to the board %q as %s. Log in or sign up with this email "+
+				"address and use the token below to accept. It expires in %d days.\n\n"+
+				"%s\n",
+			user.Username,
+			board.Name,
+			invitation.Role,
+			int(invitationTTL.Hours()/24),
+			invitation.PlainText,
+		)
+		if err := app.mailer.Send(invitation.Email, "You are invited to a board", body); err != nil {
+			app.logger.Println(err)
+		}
+	})
+	out := map[string]any{
+		"success": true,
+		"message": "Invitation sent successfully",
+		"data": map[string]any{
+			"invitation": invitation,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleInvitationDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "invitation_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Invitation not found", err)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if err := app.service.Invitation.Delete(board.ID, id); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrInvitationNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Invitation not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Invitation revoked successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleInvitationAccept(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Token string `json:"token"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Token, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	board, err := app.service.Invitation.Accept(input.Token, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrInvitationNotFound):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"token": "invalid or expired invitation token",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		case errors.Is(err, postgres.ErrAlreadyMember):
+			app.errorResponse(w, http.StatusConflict, "You are already a member of the board", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Invitation accepted successfully",
+		"data": map[string]any{
+			"board": board,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
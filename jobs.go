@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxRankLength is the rank key length past which a column's keys are
+// compacted by the rank rebalancing job.
+const maxRankLength = 12
+
+// sweepBatchSize is how many expired rows the sweeping job deletes per
+// statement, keeping each delete short.
+const sweepBatchSize = 500
+
+// staleLoginFailures is how long failed logins are remembered once their
+// lockout has passed. It is longer than any lockout policy window.
+const staleLoginFailures = 24 * time.Hour
+
+// A job is periodic maintenance work. run returns how many rows it changed;
+// report formats that count for the log and is skipped when it is zero.
+// Jobs run on every server instance, so they must be safe to run
+// concurrently with themselves.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) (int, error)
+	report   string
+}
+
+func (app *application) jobs() []job {
+	return []job{
+		{
+			name:     "rebalance ranks",
+			interval: 10 * time.Minute,
+			run: func(ctx context.Context) (int, error) {
+				return app.service.Task.Rebalance(maxRankLength)
+			},
+			report: "rebalanced rank keys in %d columns",
+		},
+		{
+			name:     "sweep expired",
+			interval: 15 * time.Minute,
+			run:      app.sweepExpired,
+			report:   "deleted %d expired tokens, invitations and login records",
+		},
+	}
+}
+
+// startJobs runs every job on its interval until ctx is cancelled. A job
+// that is running when ctx is cancelled finishes its current batch; run
+// waits for it through app.wg.
+func (app *application) startJobs(ctx context.Context) {
+	for _, j := range app.jobs() {
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				app.runJob(ctx, j)
+			}
+		}()
+	}
+}
+
+func (app *application) runJob(ctx context.Context, j job) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Println(fmt.Errorf("job %s panicked: %v", j.name, err))
+		}
+	}()
+	n, err := j.run(ctx)
+	if err != nil {
+		app.logger.Println(fmt.Errorf("job %s: %w", j.name, err))
+		return
+	}
+	if n > 0 {
+		app.logger.Printf(j.report+"\n", n)
+	}
+}
+
+// sweepExpired deletes expired tokens, refresh tokens, single sign-on login
+// states, board invitations and stale login failures in batches until none
+// are left or ctx is cancelled.
+func (app *application) sweepExpired(ctx context.Context) (int, error) {
+	sweeps := []func(batchSize int) (int, error){
+		app.service.Token.DeleteExpired,
+		app.service.Token.DeleteExpiredRefresh,
+		app.service.Identity.DeleteExpiredLoginStates,
+		app.service.Invitation.DeleteExpired,
+		func(batchSize int) (int, error) {
+			return app.service.Throttle.DeleteStale(staleLoginFailures, batchSize)
+		},
+	}
+	total := 0
+	for _, sweep := range sweeps {
+		for ctx.Err() == nil {
+			n, err := sweep(sweepBatchSize)
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if n < sweepBatchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,77 @@
+// Package mailer sends the emails the application needs to reach users
+// outside of an API response, such as password reset links.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer delivers a plain text email.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// SMTP sends email through an SMTP server. Username may be empty for
+// servers that accept mail without authentication.
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (m SMTP) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	msg := message(m.From, to, subject, body, time.Now())
+	return smtp.SendMail(addr, auth, m.From, []string{to}, msg)
+}
+
+// Writer writes each email to W instead of sending it. It is meant for
+// local development, where W is usually os.Stdout or a file, and for tests.
+type Writer struct {
+	W    io.Writer
+	From string
+
+	mu sync.Mutex
+}
+
+func (m *Writer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := message(m.From, to, subject, body, time.Now())
+	_, err := m.W.Write(append(msg, '\n'))
+	return err
+}
+
+// message formats an RFC 5322 message. Header values come from the
+// application, but line breaks are still stripped so they cannot inject
+// headers.
+func message(from, to, subject, body string, date time.Time) []byte {
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(b, "To: %s\r\n", headerValue(to))
+	fmt.Fprintf(b, "Subject: %s\r\n", headerValue(subject))
+	fmt.Fprintf(b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes()
+}
+
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessage(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := string(message(
+		"kanban@example.com",
+		"kishor@gmail.com",
+		"Reset\r\nBcc: someone@example.com",
+		"line one\nline two",
+		date,
+	))
+	want := "From: kanban@example.com\r\n" +
+		"To: kishor@gmail.com\r\n" +
+		"Subject: ResetBcc: someone@example.com\r\n" +
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		"line one\r\nline two"
+	if got != want {
+		t.Errorf("want %q; got %q", want, got)
+	}
+}
+
+func TestWriterSend(t *testing.T) {
+	b := &bytes.Buffer{}
+	m := &Writer{W: b, From: "kanban@example.com"}
+	if err := m.Send("kishor@gmail.com", "Hello", "token: abc"); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, s := range []string{"To: kishor@gmail.com\r\n", "Subject: Hello\r\n", "\r\n\r\ntoken: abc\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output %q does not contain %q", out, s)
+		}
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/KishorPokharel/kanban/mailer"
+	"github.com/KishorPokharel/kanban/oidc"
 	"github.com/KishorPokharel/kanban/postgres"
 	_ "github.com/lib/pq"
 )
@@ -18,11 +23,50 @@ func main() {
 		log.Fatal(err)
 	}
 
+	readOnlyUnverified, err := strconv.ParseBool(os.Getenv("KANBAN_READ_ONLY_UNVERIFIED"))
+	if err != nil {
+		readOnlyUnverified = true
+	}
+
+	maxLoginAttempts, err := strconv.Atoi(os.Getenv("KANBAN_MAX_LOGIN_ATTEMPTS"))
+	if err != nil || maxLoginAttempts < 1 {
+		maxLoginAttempts = 5
+	}
+
+	sso, err := newSSOProvider()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	app := &application{
+		config: config{
+			readOnlyUnverified: readOnlyUnverified,
+			emailLockout: postgres.LockoutPolicy{
+				Threshold: maxLoginAttempts,
+				BaseDelay: time.Minute,
+				MaxDelay:  time.Hour,
+				Window:    time.Hour,
+			},
+			// A shared IP, like an office behind NAT, sees the mistakes of
+			// many users, so it gets more room.
+			ipLockout: postgres.LockoutPolicy{
+				Threshold: 4 * maxLoginAttempts,
+				BaseDelay: time.Minute,
+				MaxDelay:  time.Hour,
+				Window:    time.Hour,
+			},
+		},
 		logger:  log.Default(),
 		service: postgres.NewService(db),
+		mailer:  newMailer(),
+		sso:     sso,
 	}
-	if err := app.run(); err != nil {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app.startJobs(ctx)
+	if err := app.run(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -42,3 +86,45 @@ func connectDB(dbdsn string) (*sql.DB, error) {
 
 	return db, err
 }
+
+// newMailer sends email through the SMTP server in KANBAN_SMTP_HOST, or
+// prints it to stdout when no server is configured.
+func newMailer() mailer.Mailer {
+	from := os.Getenv("KANBAN_SMTP_FROM")
+	if from == "" {
+		from = "Kanban <no-reply@kanban.local>"
+	}
+	host := os.Getenv("KANBAN_SMTP_HOST")
+	if host == "" {
+		return &mailer.Writer{W: os.Stdout, From: from}
+	}
+	port, err := strconv.Atoi(os.Getenv("KANBAN_SMTP_PORT"))
+	if err != nil {
+		port = 587
+	}
+	return mailer.SMTP{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("KANBAN_SMTP_USERNAME"),
+		Password: os.Getenv("KANBAN_SMTP_PASSWORD"),
+		From:     from,
+	}
+}
+
+// newSSOProvider connects to the OpenID Connect provider at
+// KANBAN_OIDC_ISSUER. It returns nil when single sign-on is not configured.
+func newSSOProvider() (*oidc.Provider, error) {
+	issuer := os.Getenv("KANBAN_OIDC_ISSUER")
+	if issuer == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return oidc.NewProvider(ctx, oidc.Config{
+		Issuer:       issuer,
+		ClientID:     os.Getenv("KANBAN_OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("KANBAN_OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("KANBAN_OIDC_REDIRECT_URL"),
+		Scopes:       []string{"email", "profile"},
+	})
+}
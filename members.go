@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+var memberRoles = []any{postgres.RoleOwner, postgres.RoleEditor, postgres.RoleViewer}
+
+func (app *application) handleMembersGet(w http.ResponseWriter, r *http.Request) {
+	board := app.contextGetBoard(r)
+	members, err := app.service.Member.GetAll(board.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"members": members,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleMemberAdd(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Email, validator.Required, is.Email),
+		validator.Field(&input.Role, validator.Required, validator.In(memberRoles...)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	member, err := app.service.Member.Add(board.ID, input.Email, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrUserNotFound):
+			app.errorResponse(w, http.StatusNotFound, "No user with that email", err)
+			return
+		case errors.Is(err, postgres.ErrAlreadyMember):
+			app.errorResponse(w, http.StatusConflict, "User is already a member of the board", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member added successfully",
+		"data": map[string]any{
+			"member": member,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleMemberUpdate(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.readIDParam(r, "user_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	input := struct {
+		Role string `json:"role"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Role, validator.Required, validator.In(memberRoles...)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	member, err := app.service.Member.UpdateRole(board.ID, userID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return
+		case errors.Is(err, postgres.ErrLastOwner):
+			app.errorResponse(w, http.StatusConflict, "A board needs at least one owner", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member updated successfully",
+		"data": map[string]any{
+			"member": member,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// handleMemberDelete removes a member from the board. Owners can remove
+// anyone; other members can only leave the board themselves.
+func (app *application) handleMemberDelete(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.readIDParam(r, "user_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	user := app.contextGetUser(r)
+	board := app.contextGetBoard(r)
+	if userID != user.ID && board.Role != postgres.RoleOwner {
+		app.errorResponse(w,
+			http.StatusForbidden,
+			"You do not have permission to do this on the board",
+			fmt.Errorf("board role %q cannot remove other members", board.Role),
+		)
+		return
+	}
+	if err := app.service.Member.Remove(board.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return
+		case errors.Is(err, postgres.ErrLastOwner):
+			app.errorResponse(w, http.StatusConflict, "A board needs at least one owner", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member removed successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
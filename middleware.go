@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -12,13 +13,25 @@ import (
 func (app *application) enableCors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
-		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticate admits requests made with a login session token. Personal
+// access tokens are rejected; routes open to them use requireScope.
 func (app *application) authenticate(hf http.HandlerFunc) http.HandlerFunc {
+	return app.requireScope("", hf)
+}
+
+// requireScope admits requests made with a login session token or with a
+// personal access token granted scope. An empty scope admits sessions only.
+// Board routes, which all take a scope, are read-only for users who have not
+// verified their email when config.readOnlyUnverified is set.
+func (app *application) requireScope(scope string, hf http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ah := r.Header.Get("Authorization")
 		if ah == "" {
@@ -57,11 +70,193 @@ func (app *application) authenticate(hf http.HandlerFunc) http.HandlerFunc {
 				return
 			}
 		}
+		t, err := app.service.Token.Get(token)
+		if err != nil {
+			switch {
+			case errors.Is(err, postgres.ErrTokenNotFound):
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				app.errorResponse(w,
+					http.StatusUnauthorized,
+					"Invalid Token",
+					errors.New("missing authorization token"),
+				)
+				return
+			default:
+				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+				return
+			}
+		}
+		if !t.HasScope(scope) {
+			app.errorResponse(w,
+				http.StatusForbidden,
+				"Token does not have the required scope",
+				fmt.Errorf("token lacks scope %q", scope),
+			)
+			return
+		}
+		if scope != "" && app.config.readOnlyUnverified && !user.Activated && !isReadOnly(r) {
+			app.errorResponse(w,
+				http.StatusForbidden,
+				"Activate your account to make changes",
+				errors.New("user is not activated"),
+			)
+			return
+		}
+		// Last-seen details are best effort and never fail the request.
+		if err := app.service.Token.Touch(token, r.UserAgent(), app.clientIP(r)); err != nil {
+			app.logger.Println(fmt.Errorf("touching token: %w", err))
+		}
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, token)
+		hf(w, r)
+	}
+}
+
+// requireBoard loads the board named by the :id route parameter and makes it
+// available to hf. It must be wrapped by authenticate.
+func (app *application) requireBoard(hf http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r, "id")
+		if err != nil {
+			app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+			return
+		}
+		user := app.contextGetUser(r)
+		board, err := app.service.Board.Get(user.ID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, postgres.ErrBoardNotFound):
+				app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+				return
+			default:
+				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+				return
+			}
+		}
+		r = app.contextSetBoard(r, board)
+		hf(w, r)
+	}
+}
+
+// requireRole rejects requests from board members whose role is below role.
+// It must be wrapped by requireBoard.
+func (app *application) requireRole(role string, hf http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		board := app.contextGetBoard(r)
+		if !postgres.HasRole(board.Role, role) {
+			app.errorResponse(w,
+				http.StatusForbidden,
+				"You do not have permission to do this on the board",
+				fmt.Errorf("board role %q lacks %q", board.Role, role),
+			)
+			return
+		}
+		hf(w, r)
+	}
+}
+
+// requireOrg loads the organization named by the :id route parameter if the
+// user belongs to it and makes it available to hf. It must be wrapped by
+// authenticate.
+func (app *application) requireOrg(hf http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r, "id")
+		if err != nil {
+			app.errorResponse(w, http.StatusNotFound, "Organization not found", err)
+			return
+		}
+		user := app.contextGetUser(r)
+		org, err := app.service.Org.Get(user.ID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, postgres.ErrOrgNotFound):
+				app.errorResponse(w, http.StatusNotFound, "Organization not found", err)
+				return
+			default:
+				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+				return
+			}
+		}
+		r = app.contextSetOrg(r, org)
 		hf(w, r)
 	}
 }
 
+// requireOrgRole rejects requests from organization members whose role is
+// below role. It must be wrapped by requireOrg.
+func (app *application) requireOrgRole(role string, hf http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org := app.contextGetOrg(r)
+		if !postgres.HasOrgRole(org.Role, role) {
+			app.errorResponse(w,
+				http.StatusForbidden,
+				"You do not have permission to do this in the organization",
+				fmt.Errorf("organization role %q lacks %q", org.Role, role),
+			)
+			return
+		}
+		hf(w, r)
+	}
+}
+
+// requireTeam loads the team of the organization named by the :team_id route
+// parameter and makes it available to hf. It must be wrapped by requireOrg.
+func (app *application) requireTeam(hf http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := app.readIDParam(r, "team_id")
+		if err != nil {
+			app.errorResponse(w, http.StatusNotFound, "Team not found", err)
+			return
+		}
+		org := app.contextGetOrg(r)
+		team, err := app.service.Team.Get(org.ID, id)
+		if err != nil {
+			switch {
+			case errors.Is(err, postgres.ErrTeamNotFound):
+				app.errorResponse(w, http.StatusNotFound, "Team not found", err)
+				return
+			default:
+				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+				return
+			}
+		}
+		r = app.contextSetTeam(r, team)
+		hf(w, r)
+	}
+}
+
+// requireIfMatch rejects board changes that do not name the board version
+// they were made against. The version from If-Match replaces the loaded
+// board's version, so services apply the change only if it is still current.
+// It must be wrapped by requireBoard.
+func (app *application) requireIfMatch(hf http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		version, err := app.readIfMatch(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, errMissingIfMatch):
+				app.errorResponse(w, http.StatusPreconditionRequired, "If-Match header is required", err)
+				return
+			default:
+				app.errorResponse(w, http.StatusBadRequest, "Invalid If-Match header", err)
+				return
+			}
+		}
+		board := app.contextGetBoard(r)
+		board.Version = version
+		hf(w, r)
+	}
+}
+
+func isReadOnly(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
 func (app *application) logRequest(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		now := time.Now()
@@ -0,0 +1,256 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider is an in-process OpenID Connect provider. Tests register the
+// claims an authorization code stands for with authorize.
+type fakeProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]fakeCode
+}
+
+type fakeCode struct {
+	challenge string
+	claims    map[string]any
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeProvider{t: t, key: key, kid: "key-1", codes: map[string]fakeCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 f.server.URL,
+			"authorization_endpoint": f.server.URL + "/authorize",
+			"token_endpoint":         f.server.URL + "/token",
+			"jwks_uri":               f.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		pub := f.key.PublicKey
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]any{{
+				"kty": "RSA",
+				"kid": f.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		f.mu.Lock()
+		code, ok := f.codes[r.PostForm.Get("code")]
+		delete(f.codes, r.PostForm.Get("code"))
+		f.mu.Unlock()
+		if !ok || challengeS256(r.PostForm.Get("code_verifier")) != code.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]any{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     f.sign(f.key, f.kid, code.claims),
+		})
+	})
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// authorize plays the user logging in at the provider for the request
+// behind authURL and returns the code sent back to the client.
+func (f *fakeProvider) authorize(authURL string, claims map[string]any) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		f.t.Fatalf("unexpected code_challenge_method %q", q.Get("code_challenge_method"))
+	}
+	c := map[string]any{
+		"iss":   f.server.URL,
+		"aud":   q.Get("client_id"),
+		"sub":   "user-1",
+		"nonce": q.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	code := "code-" + q.Get("state")
+	f.codes[code] = fakeCode{challenge: q.Get("code_challenge"), claims: c}
+	return code
+}
+
+func (f *fakeProvider) sign(key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]any{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestProvider(t *testing.T, f *fakeProvider) *Provider {
+	p, err := NewProvider(context.Background(), Config{
+		Issuer:       f.server.URL,
+		ClientID:     "kanban",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:3000/api/auth/oidc/callback",
+		Scopes:       []string{"email", "profile"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLogin(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newTestProvider(t, f)
+
+	verifier, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := f.authorize(p.AuthCodeURL("state-1", "nonce-1", challenge), map[string]any{
+		"email":          "kishor@gmail.com",
+		"email_verified": true,
+	})
+	claims, err := p.Login(context.Background(), code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" || claims.Email != "kishor@gmail.com" || !claims.EmailVerified {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestLoginWrongVerifier(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newTestProvider(t, f)
+
+	_, challenge, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := f.authorize(p.AuthCodeURL("state-1", "nonce-1", challenge), nil)
+	other, _, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Login(context.Background(), code, other, "nonce-1"); err == nil {
+		t.Error("want an error for a wrong code verifier")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newTestProvider(t, f)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   f.server.URL,
+			"aud":   []string{"other", "kanban"},
+			"sub":   "user-1",
+			"nonce": "nonce-1",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	with := func(key string, value any) map[string]any {
+		c := valid()
+		c[key] = value
+		return c
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: f.sign(f.key, f.kid, valid())},
+		{name: "wrong nonce", token: f.sign(f.key, f.kid, with("nonce", "nonce-2")), wantErr: ErrNonce},
+		{name: "wrong issuer", token: f.sign(f.key, f.kid, with("iss", "https://evil.example.com")), wantErr: ErrInvalidToken},
+		{name: "wrong audience", token: f.sign(f.key, f.kid, with("aud", "other")), wantErr: ErrInvalidToken},
+		{name: "expired", token: f.sign(f.key, f.kid, with("exp", time.Now().Add(-time.Hour).Unix())), wantErr: ErrInvalidToken},
+		{name: "other key", token: f.sign(otherKey, f.kid, valid()), wantErr: ErrInvalidToken},
+		{name: "unknown key", token: f.sign(otherKey, "key-2", valid()), wantErr: ErrInvalidToken},
+		{name: "not a jwt", token: "abc.def", wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		_, err := p.Verify(context.Background(), tt.token, "nonce-1")
+		if tt.wantErr == nil && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: want %v; got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+func TestVerifyUnsignedToken(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newTestProvider(t, f)
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(map[string]any{
+		"iss":   f.server.URL,
+		"aud":   "kanban",
+		"sub":   "user-1",
+		"nonce": "nonce-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	token := header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+	if _, err := p.Verify(context.Background(), token, "nonce-1"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("want %v; got %v", ErrInvalidToken, err)
+	}
+}
+
+func TestNewProviderIssuerMismatch(t *testing.T) {
+	f := newFakeProvider(t)
+	_, err := NewProvider(context.Background(), Config{Issuer: f.server.URL + "/"})
+	if err == nil {
+		t.Error("want an error when the discovered issuer differs")
+	}
+}
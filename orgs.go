@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+var orgRoles = []any{postgres.OrgRoleOwner, postgres.OrgRoleAdmin, postgres.OrgRoleMember}
+
+// orgRoleToManage is the role needed to manage a member holding role.
+// Admins manage plain members; owners and admins are managed by owners.
+func orgRoleToManage(role string) string {
+	if role == postgres.OrgRoleMember {
+		return postgres.OrgRoleAdmin
+	}
+	return postgres.OrgRoleOwner
+}
+
+func (app *application) forbidOrgResponse(w http.ResponseWriter, org *postgres.Org, role string) {
+	app.errorResponse(w,
+		http.StatusForbidden,
+		"You do not have permission to do this in the organization",
+		fmt.Errorf("organization role %q lacks %q", org.Role, role),
+	)
+}
+
+func (app *application) handleOrgsGet(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	orgs, err := app.service.Org.GetAll(user.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"orgs": orgs,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleOrgCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 100)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	org := &postgres.Org{Name: input.Name}
+	if err := app.service.Org.Create(org, user.ID); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Organization created successfully",
+		"data": map[string]any{
+			"org": org,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleOrgGet(w http.ResponseWriter, r *http.Request) {
+	org := app.contextGetOrg(r)
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"org": org,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleOrgDelete(w http.ResponseWriter, r *http.Request) {
+	org := app.contextGetOrg(r)
+	if err := app.service.Org.Delete(org.ID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrOrgNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Organization not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Organization deleted successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleOrgMembersGet(w http.ResponseWriter, r *http.Request) {
+	org := app.contextGetOrg(r)
+	members, err := app.service.Org.GetMembers(org.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"members": members,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleOrgMemberAdd(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Email, validator.Required, is.Email),
+		validator.Field(&input.Role, validator.Required, validator.In(orgRoles...)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	org := app.contextGetOrg(r)
+	if need := orgRoleToManage(input.Role); !postgres.HasOrgRole(org.Role, need) {
+		app.forbidOrgResponse(w, org, need)
+		return
+	}
+	member, err := app.service.Org.AddMember(org.ID, input.Email, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrUserNotFound):
+			app.errorResponse(w, http.StatusNotFound, "No user with that email", err)
+			return
+		case errors.Is(err, postgres.ErrAlreadyOrgMember):
+			app.errorResponse(w, http.StatusConflict, "User is already a member of the organization", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member added successfully",
+		"data": map[string]any{
+			"member": member,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleOrgMemberUpdate(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.readIDParam(r, "user_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	input := struct {
+		Role string `json:"role"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Role, validator.Required, validator.In(orgRoles...)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	org := app.contextGetOrg(r)
+	member, ok := app.orgMemberToManage(w, org, userID)
+	if !ok {
+		return
+	}
+	if need := orgRoleToManage(input.Role); !postgres.HasOrgRole(org.Role, need) {
+		app.forbidOrgResponse(w, org, need)
+		return
+	}
+	member, err = app.service.Org.UpdateMemberRole(org.ID, member.UserID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrOrgMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return
+		case errors.Is(err, postgres.ErrLastOrgOwner):
+			app.errorResponse(w, http.StatusConflict, "An organization needs at least one owner", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member updated successfully",
+		"data": map[string]any{
+			"member": member,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// handleOrgMemberDelete removes a member from the organization. Anyone can
+// leave an organization; removing others takes the role orgRoleToManage
+// asks for.
+func (app *application) handleOrgMemberDelete(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.readIDParam(r, "user_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	user := app.contextGetUser(r)
+	org := app.contextGetOrg(r)
+	if userID != user.ID {
+		if _, ok := app.orgMemberToManage(w, org, userID); !ok {
+			return
+		}
+	}
+	if err := app.service.Org.RemoveMember(org.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrOrgMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return
+		case errors.Is(err, postgres.ErrLastOrgOwner):
+			app.errorResponse(w, http.StatusConflict, "An organization needs at least one owner", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member removed successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// orgMemberToManage loads the member with userID and checks the requesting
+// user may manage them. It writes the error response and returns false if
+// not.
+func (app *application) orgMemberToManage(w http.ResponseWriter, org *postgres.Org, userID int64) (*postgres.Member, bool) {
+	member, err := app.service.Org.GetMember(org.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrOrgMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return nil, false
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return nil, false
+		}
+	}
+	if need := orgRoleToManage(member.Role); !postgres.HasOrgRole(org.Role, need) {
+		app.forbidOrgResponse(w, org, need)
+		return nil, false
+	}
+	return member, true
+}
+
+// handleOrgBoardsGet lists the boards of the organization the user has a
+// role on.
+func (app *application) handleOrgBoardsGet(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	org := app.contextGetOrg(r)
+	all, err := app.service.Board.GetAll(user.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	boards := []postgres.Board{}
+	for _, board := range all {
+		if board.OrgID != nil && *board.OrgID == org.ID {
+			boards = append(boards, board)
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"boards": boards,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleOrgBoardCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 100)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	org := app.contextGetOrg(r)
+	board := &postgres.Board{
+		UserID: user.ID,
+		OrgID:  &org.ID,
+		Name:   input.Name,
+	}
+	if err := app.service.Board.Create(board); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Board created successfully",
+		"data": map[string]any{
+			"board": board,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
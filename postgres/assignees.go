@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var ErrAssigneeNotMember = errors.New("assignee is not a member of the board")
+
+// Assignee summarises a user a task is assigned to.
+type Assignee struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// assignedOnBoard limits task_assignees rows to users who still have a role
+// on the task's board. Assignments are left behind when someone loses access,
+// through any of the ways board_roles can change, and come back into effect
+// if they are given access again.
+const assignedOnBoard = `
+            exists (
+                select 1 from board_roles
+                where board_roles.board_id = tasks.board_id
+                and board_roles.user_id = task_assignees.user_id
+            )`
+
+// assigneeColumns selects the ids and usernames of a task's assignees as two
+// arrays in the same order, for queries over tasks to scan with
+// newAssignees.
+const assigneeColumns = `
+        array(
+            select user_id from task_assignees
+            where task_id = tasks.id and` + assignedOnBoard + `
+            order by user_id
+        ),
+        array(
+            select username from task_assignees
+            join users on users.id = task_assignees.user_id
+            where task_id = tasks.id and` + assignedOnBoard + `
+            order by user_id
+        )`
+
+func newAssignees(ids []int64, usernames []string) []Assignee {
+	assignees := make([]Assignee, len(ids))
+	for i := range ids {
+		assignees[i] = Assignee{UserID: ids[i], Username: usernames[i]}
+	}
+	return assignees
+}
+
+// SetAssignees replaces the users the task is assigned to. Every one of them
+// must have a role on the board.
+func (ts TaskService) SetAssignees(board *Board, taskID int64, userIDs []int64) ([]Assignee, error) {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return nil, err
+	}
+
+	queryTask := `
+        select id from tasks
+        where id = $1 and board_id = $2
+        for update
+    `
+	var id int64
+	row := tx.QueryRowContext(context.Background(), queryTask, taskID, board.ID)
+	if err := row.Scan(&id); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrTaskNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	queryMembers := `
+        select count(*) from board_roles
+        where board_id = $1 and user_id = any($2)
+    `
+	ids := uniqueIDs(userIDs)
+	var members int
+	row = tx.QueryRowContext(context.Background(), queryMembers, board.ID, pq.Array(ids))
+	if err := row.Scan(&members); err != nil {
+		return nil, err
+	}
+	if members != len(ids) {
+		return nil, ErrAssigneeNotMember
+	}
+
+	queryDelete := `delete from task_assignees where task_id = $1`
+	if _, err := tx.ExecContext(context.Background(), queryDelete, taskID); err != nil {
+		return nil, err
+	}
+	queryInsert := `
+        insert into task_assignees (task_id, user_id)
+        select $1, unnest($2::bigint[])
+    `
+	if _, err := tx.ExecContext(context.Background(), queryInsert, taskID, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	query := `
+        select users.id, username from task_assignees
+        join users on users.id = task_assignees.user_id
+        where task_id = $1
+        order by users.id
+    `
+	rows, err := tx.QueryContext(context.Background(), query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assignees := []Assignee{}
+	for rows.Next() {
+		assignee := Assignee{}
+		if err := rows.Scan(&assignee.UserID, &assignee.Username); err != nil {
+			return nil, err
+		}
+		assignees = append(assignees, assignee)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	board.Version = version
+	return assignees, nil
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := map[int64]bool{}
+	unique := []int64{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
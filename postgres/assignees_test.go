@@ -0,0 +1,106 @@
+package postgres
+
+import "testing"
+
+func TestTaskAssignees(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	owner := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	owner.Password.Set("kishor123")
+	if err := service.User.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	other := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	other.Password.Set("bibek123")
+	if err := service.User.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, owner)
+	assigned := &Task{BoardID: board.ID, UserID: owner.ID, Content: "A"}
+	if err := service.Task.Insert(board, assigned, false); err != nil {
+		t.Fatal(err)
+	}
+	unassigned := &Task{BoardID: board.ID, UserID: owner.ID, Content: "B"}
+	if err := service.Task.Insert(board, unassigned, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.Task.SetAssignees(board, assigned.ID, []int64{other.ID}); err != ErrAssigneeNotMember {
+		t.Errorf("want %v; got %v", ErrAssigneeNotMember, err)
+	}
+	if _, err := service.Member.Add(board.ID, other.Email, RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	assignees, err := service.Task.SetAssignees(board, assigned.ID, []int64{other.ID, owner.ID, other.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assignees) != 2 || assignees[0].UserID != owner.ID || assignees[1].Username != other.Username {
+		t.Errorf("want both users assigned, got %+v", assignees)
+	}
+
+	columns, err := service.Task.GetAllAssigned(board.ID, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, c := range columns {
+		for _, task := range c.Tasks {
+			got = append(got, task.Content)
+			if len(task.Assignees) != 2 {
+				t.Errorf("task %d: want 2 assignees, got %+v", task.ID, task.Assignees)
+			}
+		}
+	}
+	if len(got) != 1 || got[0] != "A" {
+		t.Errorf("filter by assignee failed, got = %v", got)
+	}
+
+	if _, err := service.Task.SetAssignees(board, assigned.ID, []int64{}); err != nil {
+		t.Fatal(err)
+	}
+	columns, err = service.Task.GetAllAssigned(board.ID, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range columns {
+		if len(c.Tasks) != 0 {
+			t.Errorf("unassigned task should be filtered out, got %+v", c.Tasks)
+		}
+	}
+
+	if _, err := service.Task.SetAssignees(board, assigned.ID, []int64{owner.ID, other.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Member.Remove(board.ID, other.ID); err != nil {
+		t.Fatal(err)
+	}
+	columns, err = service.Task.GetAllAssigned(board.ID, other.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range columns {
+		if len(c.Tasks) != 0 {
+			t.Errorf("removed member should have no tasks, got %+v", c.Tasks)
+		}
+	}
+	columns, err = service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range columns {
+		for _, task := range c.Tasks {
+			if task.ID == assigned.ID && (len(task.Assignees) != 1 || task.Assignees[0].UserID != owner.ID) {
+				t.Errorf("removed member should not be listed as assignee, got %+v", task.Assignees)
+			}
+		}
+	}
+}
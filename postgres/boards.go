@@ -0,0 +1,258 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrBoardNotFound = errors.New("board not found")
+	ErrEditConflict  = errors.New("board was changed by another request")
+)
+
+type Board struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"user_id"`
+	// OrgID is the organization owning the board, or nil for a board of its
+	// own.
+	OrgID *int64 `json:"org_id,omitempty"`
+	Name  string `json:"name"`
+	// Version is incremented by every change to the board's columns or
+	// tasks. Changes are only applied when the caller's Version is current.
+	Version   int64     `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Role is the role on the board of the user it was loaded for, granted
+	// directly, through a team or by administering the board's organization.
+	// UserID stays the user who created the board.
+	Role string `json:"role,omitempty"`
+}
+
+type BoardService struct {
+	DB *sql.DB
+}
+
+// insertBoard creates the board and its default columns inside tx and makes
+// its creator an owner, so callers creating a board as part of a larger unit
+// of work (registering a user) get the same setup as BoardService.Create.
+func insertBoard(tx *sql.Tx, board *Board) error {
+	queryInsertBoard := `
+        insert into boards (user_id, org_id, name)
+        values ($1, $2, $3)
+        returning id, version, created_at
+    `
+	args := []any{board.UserID, board.OrgID, board.Name}
+	row := tx.QueryRowContext(context.Background(), queryInsertBoard, args...)
+	if err := row.Scan(&board.ID, &board.Version, &board.CreatedAt); err != nil {
+		return err
+	}
+	if err := insertMember(tx, board.ID, board.UserID, RoleOwner); err != nil {
+		return err
+	}
+	board.Role = RoleOwner
+
+	for _, c := range defaultColumns {
+		column := &Column{
+			BoardID: board.ID,
+			Name:    c.Name,
+			Color:   c.Color,
+		}
+		if err := insertColumn(tx, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bumpVersion moves the board from board.Version to the next version inside
+// tx and returns the new version, or ErrEditConflict when the board has
+// changed since the caller read it. The board row stays locked until tx ends,
+// which serializes concurrent changes to the same board.
+func bumpVersion(tx *sql.Tx, board *Board) (int64, error) {
+	query := `
+        update boards
+        set version = version + 1
+        where id = $1 and version = $2
+        returning version
+    `
+	var version int64
+	row := tx.QueryRowContext(context.Background(), query, board.ID, board.Version)
+	if err := row.Scan(&version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrEditConflict
+		default:
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+func (bs BoardService) Create(board *Board) error {
+	tx, err := bs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertBoard(tx, board); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAll returns the boards userID has a role on.
+func (bs BoardService) GetAll(userID int64) ([]Board, error) {
+	query := `
+        select boards.id, coalesce(boards.user_id, 0), org_id, name, version, board_roles.role, boards.created_at
+        from boards
+        join board_roles on board_roles.board_id = boards.id
+        where board_roles.user_id = $1
+        order by boards.id
+    `
+	rows, err := bs.DB.QueryContext(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	boards := []Board{}
+	for rows.Next() {
+		board := Board{}
+		err := rows.Scan(
+			&board.ID,
+			&board.UserID,
+			&board.OrgID,
+			&board.Name,
+			&board.Version,
+			&board.Role,
+			&board.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		boards = append(boards, board)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return boards, nil
+}
+
+// Get returns the board if userID has a role on it, along with the role.
+func (bs BoardService) Get(userID, boardID int64) (*Board, error) {
+	query := `
+        select boards.id, coalesce(boards.user_id, 0), org_id, name, version, board_roles.role, boards.created_at
+        from boards
+        join board_roles on board_roles.board_id = boards.id
+        where boards.id = $1 and board_roles.user_id = $2
+    `
+	row := bs.DB.QueryRowContext(context.Background(), query, boardID, userID)
+	board := Board{}
+	err := row.Scan(
+		&board.ID,
+		&board.UserID,
+		&board.OrgID,
+		&board.Name,
+		&board.Version,
+		&board.Role,
+		&board.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrBoardNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &board, nil
+}
+
+func (bs BoardService) Update(board *Board) error {
+	tx, err := bs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	query := `
+        update boards
+        set name = $1
+        where id = $2
+        returning created_at
+    `
+	args := []any{board.Name, board.ID}
+	row := tx.QueryRowContext(context.Background(), query, args...)
+	err = row.Scan(&board.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrBoardNotFound
+		default:
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
+
+// Delete removes the board together with its tasks and orderings. userID
+// must be an owner of the board, and board.Version its current version.
+func (bs BoardService) Delete(userID int64, board *Board) error {
+	tx, err := bs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queryLockBoard := `
+        select boards.version from boards
+        join board_roles on board_roles.board_id = boards.id
+        where boards.id = $1 and board_roles.user_id = $2 and board_roles.role = 'owner'
+        for update of boards
+    `
+	var version int64
+	row := tx.QueryRowContext(context.Background(), queryLockBoard, board.ID, userID)
+	if err := row.Scan(&version); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrBoardNotFound
+		default:
+			return err
+		}
+	}
+	if version != board.Version {
+		return ErrEditConflict
+	}
+
+	queries := []string{
+		`delete from tasks where board_id = $1`,
+		`delete from board_columns where board_id = $1`,
+		`delete from board_members where board_id = $1`,
+		`delete from boards where id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(context.Background(), query, board.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
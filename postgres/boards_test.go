@@ -0,0 +1,153 @@
+package postgres
+
+import "testing"
+
+func TestUserCreateSeedsDefaultBoard(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	boards, err := service.Board.GetAll(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boards) != 1 || boards[0].Name != defaultBoardName {
+		t.Fatalf("want one default board, got = %v", boards)
+	}
+	tasks, err := service.Task.GetAll(boards[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != len(defaultColumns) {
+		t.Fatalf("want %d default columns, got = %v", len(defaultColumns), tasks)
+	}
+	for i, c := range defaultColumns {
+		if tasks[i].Name != c.Name {
+			t.Errorf("default column %d: want %s, got %s", i, c.Name, tasks[i].Name)
+		}
+	}
+}
+
+func TestBoardGetScopedToUser(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	owner := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	owner.Password.Set("kishor123")
+	if err := service.User.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	other := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	other.Password.Set("bibek123")
+	if err := service.User.Create(other); err != nil {
+		t.Fatal(err)
+	}
+
+	board := newTestBoard(t, service, owner)
+	if _, err := service.Board.Get(owner.ID, board.ID); err != nil {
+		t.Errorf("owner should see the board, got %v", err)
+	}
+	if _, err := service.Board.Get(other.ID, board.ID); err != ErrBoardNotFound {
+		t.Errorf("want %v; got %v", ErrBoardNotFound, err)
+	}
+}
+
+func TestBoardDelete(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+	task := &Task{
+		BoardID: board.ID,
+		UserID:  user.ID,
+		Content: "A",
+	}
+	stale := *board
+	if err := service.Task.Insert(board, task, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Board.Delete(user.ID, &stale); err != ErrEditConflict {
+		t.Errorf("stale version: want %v; got %v", ErrEditConflict, err)
+	}
+	if err := service.Board.Delete(user.ID, board); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Board.Get(user.ID, board.ID); err != ErrBoardNotFound {
+		t.Errorf("want %v; got %v", ErrBoardNotFound, err)
+	}
+	if err := service.Board.Delete(user.ID, board); err != ErrBoardNotFound {
+		t.Errorf("want %v; got %v", ErrBoardNotFound, err)
+	}
+}
+
+func TestBoardVersionConflict(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+	stale := *board
+
+	task := &Task{UserID: user.ID, Content: "A"}
+	if err := service.Task.Insert(board, task, false); err != nil {
+		t.Fatal(err)
+	}
+	if board.Version != stale.Version+1 {
+		t.Errorf("want version %d, got %d", stale.Version+1, board.Version)
+	}
+
+	other := &Task{UserID: user.ID, Content: "B"}
+	if err := service.Task.Insert(&stale, other, false); err != ErrEditConflict {
+		t.Errorf("want %v; got %v", ErrEditConflict, err)
+	}
+	if err := service.Task.Archive(&stale, task.ID); err != ErrEditConflict {
+		t.Errorf("want %v; got %v", ErrEditConflict, err)
+	}
+	renamed := stale
+	renamed.Name = "Renamed"
+	if err := service.Board.Update(&renamed); err != ErrEditConflict {
+		t.Errorf("want %v; got %v", ErrEditConflict, err)
+	}
+
+	current, err := service.Board.Get(user.ID, board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Version != board.Version {
+		t.Errorf("rejected changes should not bump the version, want %d, got %d", board.Version, current.Version)
+	}
+}
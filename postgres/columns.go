@@ -0,0 +1,338 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrColumnNotFound   = errors.New("column not found")
+	ErrColumnNotEmpty   = errors.New("column still has tasks")
+	ErrLastColumn       = errors.New("a board needs at least one column")
+	ErrWIPLimitExceeded = errors.New("column work in progress limit exceeded")
+)
+
+// defaultColumns are the columns every new board starts with.
+var defaultColumns = []Column{
+	{Name: "TODO", Color: "#9e9e9e"},
+	{Name: "IN PROGRESS", Color: "#2196f3"},
+	{Name: "TESTING", Color: "#ff9800"},
+	{Name: "DONE", Color: "#4caf50"},
+}
+
+type Column struct {
+	ID       int64  `json:"id"`
+	BoardID  int64  `json:"board_id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Position int    `json:"position"`
+	// WIPLimit is the most unarchived tasks the column may hold; zero means
+	// no limit.
+	WIPLimit  int       `json:"wip_limit"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ColumnService struct {
+	DB *sql.DB
+}
+
+// insertColumn appends the column to the end of its board.
+func insertColumn(tx *sql.Tx, column *Column) error {
+	queryInsertColumn := `
+        insert into board_columns (board_id, name, color, position, wip_limit)
+        select $1, $2, $3, coalesce(max(position) + 1, 0), nullif($4, 0)
+        from board_columns where board_id = $1
+        returning id, position, created_at
+    `
+	args := []any{column.BoardID, column.Name, column.Color, column.WIPLimit}
+	row := tx.QueryRowContext(context.Background(), queryInsertColumn, args...)
+	return row.Scan(&column.ID, &column.Position, &column.CreatedAt)
+}
+
+// lockColumns locks the board's columns with the given ids until tx ends.
+// Every change to the task order of a column holds its lock while it reads
+// the rank keys it places a task between, so no concurrent change can
+// invalidate them. Rows are locked in id order to avoid deadlocks.
+func lockColumns(tx *sql.Tx, boardID int64, columnIDs ...int64) error {
+	query := `
+        select id from board_columns
+        where board_id = $1 and id = any($2)
+        order by id
+        for update
+    `
+	rows, err := tx.QueryContext(context.Background(), query, boardID, pq.Array(columnIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	locked := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		locked[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range columnIDs {
+		if !locked[id] {
+			return ErrColumnNotFound
+		}
+	}
+	return nil
+}
+
+// checkWIPLimit locks the column row and reports ErrWIPLimitExceeded when it
+// cannot take one more task. Holding the lock until tx ends keeps two
+// concurrent moves from both squeezing into the last free slot.
+func checkWIPLimit(tx *sql.Tx, columnID int64) error {
+	query := `
+        select coalesce(wip_limit, 0),
+        (select count(*) from tasks where column_id = $1 and archived_at is null)
+        from board_columns
+        where id = $1
+        for update
+    `
+	var limit, count int
+	row := tx.QueryRowContext(context.Background(), query, columnID)
+	if err := row.Scan(&limit, &count); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrColumnNotFound
+		default:
+			return err
+		}
+	}
+	if limit > 0 && count >= limit {
+		return ErrWIPLimitExceeded
+	}
+	return nil
+}
+
+func (cs ColumnService) GetAll(boardID int64) ([]Column, error) {
+	query := `
+        select id, board_id, name, color, position, coalesce(wip_limit, 0), created_at
+        from board_columns
+        where board_id = $1
+        order by position
+    `
+	rows, err := cs.DB.QueryContext(context.Background(), query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []Column{}
+	for rows.Next() {
+		column := Column{}
+		err := rows.Scan(
+			&column.ID,
+			&column.BoardID,
+			&column.Name,
+			&column.Color,
+			&column.Position,
+			&column.WIPLimit,
+			&column.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+func (cs ColumnService) Get(boardID, columnID int64) (*Column, error) {
+	query := `
+        select id, board_id, name, color, position, coalesce(wip_limit, 0), created_at
+        from board_columns
+        where id = $1 and board_id = $2
+    `
+	row := cs.DB.QueryRowContext(context.Background(), query, columnID, boardID)
+	column := Column{}
+	err := row.Scan(
+		&column.ID,
+		&column.BoardID,
+		&column.Name,
+		&column.Color,
+		&column.Position,
+		&column.WIPLimit,
+		&column.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrColumnNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &column, nil
+}
+
+func (cs ColumnService) Create(board *Board, column *Column) error {
+	tx, err := cs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	column.BoardID = board.ID
+	if err := insertColumn(tx, column); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
+
+func (cs ColumnService) Update(board *Board, column *Column) error {
+	tx, err := cs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	query := `
+        update board_columns
+        set name = $1, color = $2, wip_limit = nullif($3, 0)
+        where id = $4 and board_id = $5
+        returning position, created_at
+    `
+	args := []any{column.Name, column.Color, column.WIPLimit, column.ID, board.ID}
+	row := tx.QueryRowContext(context.Background(), query, args...)
+	err = row.Scan(&column.Position, &column.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrColumnNotFound
+		default:
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
+
+// Move places the column at destinationIndex and renumbers the positions of
+// every column on the board.
+func (cs ColumnService) Move(board *Board, columnID, destinationIndex int64) error {
+	tx, err := cs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        select coalesce(array_agg(id order by position), array[]::bigint[])
+        from board_columns
+        where board_id = $1
+    `
+	ids := []int64{}
+	row := tx.QueryRowContext(context.Background(), query, board.ID)
+	if err := row.Scan(pq.Array(&ids)); err != nil {
+		return err
+	}
+	idx, ok := taskIdInArray(columnID, ids)
+	if !ok {
+		return ErrColumnNotFound
+	}
+	if destinationIndex > int64(len(ids)-1) {
+		return ErrInvalidData
+	}
+	move(columnID, idx, destinationIndex, ids)
+
+	queryUpdate := `
+        update board_columns
+        set position = x.position - 1
+        from unnest($1::bigint[]) with ordinality as x(id, position)
+        where board_columns.id = x.id
+    `
+	_, err = tx.ExecContext(context.Background(), queryUpdate, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
+
+// Delete removes an empty column. Columns holding tasks, archived ones
+// included, and the last column of a board cannot be deleted.
+func (cs ColumnService) Delete(board *Board, columnID int64) error {
+	tx, err := cs.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        select
+            exists (select 1 from board_columns where id = $1 and board_id = $2),
+            exists (select 1 from tasks where column_id = $1),
+            (select count(*) from board_columns where board_id = $2)
+    `
+	var found, hasTasks bool
+	var count int
+	row := tx.QueryRowContext(context.Background(), query, columnID, board.ID)
+	if err := row.Scan(&found, &hasTasks, &count); err != nil {
+		return err
+	}
+	switch {
+	case !found:
+		return ErrColumnNotFound
+	case hasTasks:
+		return ErrColumnNotEmpty
+	case count <= 1:
+		return ErrLastColumn
+	}
+
+	queryDelete := `delete from board_columns where id = $1`
+	if _, err := tx.ExecContext(context.Background(), queryDelete, columnID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
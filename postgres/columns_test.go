@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestColumnCreateAndMove(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+
+	column := &Column{
+		BoardID: board.ID,
+		Name:    "REVIEW",
+		Color:   "#ffffff",
+	}
+	if err := service.Column.Create(board, column); err != nil {
+		t.Fatal(err)
+	}
+	if column.Position != len(defaultColumns) {
+		t.Errorf("new column should be appended, got position = %d", column.Position)
+	}
+
+	if err := service.Column.Move(board, column.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	columns, err := service.Column.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"TODO", "REVIEW", "IN PROGRESS", "TESTING", "DONE"}
+	got := []string{}
+	for i, c := range columns {
+		if c.Position != i {
+			t.Errorf("column %s: want position %d, got %d", c.Name, i, c.Position)
+		}
+		got = append(got, c.Name)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("move column failed, got = %v, want = %v", got, want)
+	}
+
+	column.Name = "CODE REVIEW"
+	column.Color = "#000000"
+	if err := service.Column.Update(board, column); err != nil {
+		t.Fatal(err)
+	}
+	if column.Position != 1 {
+		t.Errorf("update should not move the column, got position = %d", column.Position)
+	}
+}
+
+func TestColumnDelete(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+	columns, err := service.Column.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := &Task{
+		BoardID:  board.ID,
+		ColumnID: columns[1].ID,
+		UserID:   user.ID,
+		Content:  "A",
+	}
+	if err := service.Task.Insert(board, task, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Column.Delete(board, columns[1].ID); err != ErrColumnNotEmpty {
+		t.Errorf("want %v; got %v", ErrColumnNotEmpty, err)
+	}
+
+	for _, c := range columns[2:] {
+		if err := service.Column.Delete(board, c.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := service.Column.Delete(board, columns[2].ID); err != ErrColumnNotFound {
+		t.Errorf("want %v; got %v", ErrColumnNotFound, err)
+	}
+	if err := service.Task.Delete(board, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Column.Delete(board, columns[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Column.Delete(board, columns[0].ID); err != ErrLastColumn {
+		t.Errorf("want %v; got %v", ErrLastColumn, err)
+	}
+}
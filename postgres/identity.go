@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+var ErrEmailNotVerified = errors.New("email address is not verified by the identity provider")
+
+// Identity is a user account at an external OpenID Connect provider.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+}
+
+// IdentityService links users to accounts at external identity providers
+// and keeps the state of logins in progress with them.
+type IdentityService struct {
+	DB *sql.DB
+}
+
+// SaveLoginState remembers the nonce and PKCE verifier of a login that was
+// sent to the provider with state, until UseLoginState picks them up.
+func (is IdentityService) SaveLoginState(state, nonce, verifier string, ttl time.Duration) error {
+	hash := sha256.Sum256([]byte(state))
+	query := `
+        insert into oidc_logins (state_hash, nonce, verifier, expiry)
+        values ($1, $2, $3, $4)
+    `
+	args := []any{hash[:], nonce, verifier, time.Now().Add(ttl)}
+	_, err := is.DB.ExecContext(context.Background(), query, args...)
+	return err
+}
+
+// UseLoginState returns the nonce and PKCE verifier saved for state and
+// forgets them, so each state is accepted once.
+func (is IdentityService) UseLoginState(state string) (string, string, error) {
+	hash := sha256.Sum256([]byte(state))
+	query := `
+        delete from oidc_logins
+        where state_hash = $1 and expiry > now()
+        returning nonce, verifier
+    `
+	var nonce, verifier string
+	row := is.DB.QueryRowContext(context.Background(), query, hash[:])
+	if err := row.Scan(&nonce, &verifier); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", "", ErrTokenNotFound
+		default:
+			return "", "", err
+		}
+	}
+	return nonce, verifier, nil
+}
+
+// GetOrCreateUser returns the user linked to identity. An identity seen
+// for the first time is linked to the user with the same email address, or
+// to a new user when there is none. Either way the email must be verified
+// by the provider, which also counts as verifying it here.
+func (is IdentityService) GetOrCreateUser(identity Identity) (*User, error) {
+	tx, err := is.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	queryLinked := `
+        select users.id, users.username, users.email, users.activated, users.totp_enabled, users.created_at
+        from users
+        join user_identities on user_identities.user_id = users.id
+        where user_identities.issuer = $1 and user_identities.subject = $2
+    `
+	user := User{}
+	row := tx.QueryRowContext(context.Background(), queryLinked, identity.Issuer, identity.Subject)
+	err = row.Scan(&user.ID, &user.Username, &user.Email, &user.Activated, &user.TOTPEnabled, &user.CreatedAt)
+	switch {
+	case err == nil:
+		return &user, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	}
+
+	if !identity.EmailVerified || identity.Email == "" {
+		return nil, ErrEmailNotVerified
+	}
+
+	queryByEmail := `
+        update users
+        set activated = true
+        where email = $1
+        returning id, username, email, activated, totp_enabled, created_at
+    `
+	row = tx.QueryRowContext(context.Background(), queryByEmail, identity.Email)
+	err = row.Scan(&user.ID, &user.Username, &user.Email, &user.Activated, &user.TOTPEnabled, &user.CreatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := insertIdentityUser(tx, &user, identity); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	queryLink := `
+        insert into user_identities (user_id, issuer, subject)
+        values ($1, $2, $3)
+    `
+	if _, err := tx.ExecContext(context.Background(), queryLink, user.ID, identity.Issuer, identity.Subject); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// insertIdentityUser creates an activated user, with a default board, for
+// an identity. The user gets a random password nobody knows; they can set
+// one through the password reset flow.
+func insertIdentityUser(tx *sql.Tx, user *User, identity Identity) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	user.Username = identity.Username
+	user.Email = identity.Email
+	user.Activated = true
+	if err := user.Password.Set(base32.StdEncoding.EncodeToString(b)); err != nil {
+		return err
+	}
+	return insertUser(tx, user)
+}
+
+// DeleteExpiredLoginStates deletes up to batchSize login states that were
+// never used and returns how many it deleted.
+func (is IdentityService) DeleteExpiredLoginStates(batchSize int) (int, error) {
+	query := `
+        delete from oidc_logins
+        where state_hash in (
+            select state_hash from oidc_logins
+            where expiry < now()
+            limit $1
+            for update skip locked
+        )
+    `
+	return deleteBatch(is.DB, query, batchSize)
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdentityGetOrCreateUser(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	local := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	local.Password.Set("kishor123")
+	if err := service.User.Create(local); err != nil {
+		t.Fatal(err)
+	}
+
+	unverified := Identity{
+		Issuer:   "https://id.example.com",
+		Subject:  "1",
+		Email:    "kishor@gmail.com",
+		Username: "kishor",
+	}
+	if _, err := service.Identity.GetOrCreateUser(unverified); err != ErrEmailNotVerified {
+		t.Errorf("want %v; got %v", ErrEmailNotVerified, err)
+	}
+
+	linked := unverified
+	linked.EmailVerified = true
+	user, err := service.Identity.GetOrCreateUser(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != local.ID || !user.Activated {
+		t.Errorf("should link to the local user, got %+v", user)
+	}
+	// Once linked the identity keeps its user, whatever email it reports.
+	linked.Email = "kishor@example.com"
+	linked.EmailVerified = false
+	user, err = service.Identity.GetOrCreateUser(linked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != local.ID {
+		t.Errorf("want user %d; got %d", local.ID, user.ID)
+	}
+
+	provisioned, err := service.Identity.GetOrCreateUser(Identity{
+		Issuer:        "https://id.example.com",
+		Subject:       "2",
+		Email:         "bibek@gmail.com",
+		EmailVerified: true,
+		Username:      "bibek",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provisioned.ID == local.ID || provisioned.Email != "bibek@gmail.com" || !provisioned.Activated {
+		t.Errorf("unexpected provisioned user %+v", provisioned)
+	}
+	boards, err := service.Board.GetAll(provisioned.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boards) != 1 {
+		t.Errorf("provisioned users should get a default board, got %d boards", len(boards))
+	}
+}
+
+func TestIdentityLoginState(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	if err := service.Identity.SaveLoginState("state", "nonce", "verifier", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	nonce, verifier, err := service.Identity.UseLoginState("state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce != "nonce" || verifier != "verifier" {
+		t.Errorf("unexpected nonce %q and verifier %q", nonce, verifier)
+	}
+	if _, _, err := service.Identity.UseLoginState("state"); err != ErrTokenNotFound {
+		t.Errorf("want %v; got %v", ErrTokenNotFound, err)
+	}
+}
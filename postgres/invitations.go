@@ -0,0 +1,243 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// Invitation offers whoever controls Email a role on a board. PlainText is
+// only set when the invitation is created, to be mailed to the invitee.
+type Invitation struct {
+	ID        int64     `json:"id"`
+	BoardID   int64     `json:"board_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	InvitedBy int64     `json:"invited_by"`
+	Expiry    time.Time `json:"expiry"`
+	CreatedAt time.Time `json:"created_at"`
+	PlainText string    `json:"-"`
+}
+
+type InvitationService struct {
+	DB *sql.DB
+}
+
+// New invites email to the board. Inviting an address that already has a
+// pending invitation to the board replaces it, so the earlier link stops
+// working. It returns ErrAlreadyMember if the address belongs to a member.
+func (is InvitationService) New(invitation *Invitation, ttl time.Duration) error {
+	plainText, hash, err := randomSecret()
+	if err != nil {
+		return err
+	}
+	invitation.PlainText = plainText
+	invitation.Expiry = time.Now().Add(ttl)
+
+	tx, err := is.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queryMember := `
+        select exists (
+            select 1 from board_members
+            join users on users.id = board_members.user_id
+            where board_id = $1 and lower(users.email) = lower($2)
+        )
+    `
+	var member bool
+	row := tx.QueryRowContext(context.Background(), queryMember, invitation.BoardID, invitation.Email)
+	if err := row.Scan(&member); err != nil {
+		return err
+	}
+	if member {
+		return ErrAlreadyMember
+	}
+
+	query := `
+        insert into board_invitations (board_id, email, role, hash, invited_by, expiry)
+        values ($1, $2, $3, $4, $5, $6)
+        on conflict (board_id, lower(email)) do update
+        set email = excluded.email, role = excluded.role, hash = excluded.hash,
+        invited_by = excluded.invited_by, expiry = excluded.expiry, created_at = now()
+        returning id, created_at
+    `
+	args := []any{
+		invitation.BoardID,
+		invitation.Email,
+		invitation.Role,
+		hash,
+		invitation.InvitedBy,
+		invitation.Expiry,
+	}
+	row = tx.QueryRowContext(context.Background(), query, args...)
+	if err := row.Scan(&invitation.ID, &invitation.CreatedAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAll returns the board's invitations that have not expired.
+func (is InvitationService) GetAll(boardID int64) ([]Invitation, error) {
+	query := `
+        select id, board_id, email, role, invited_by, expiry, created_at
+        from board_invitations
+        where board_id = $1 and expiry > now()
+        order by created_at, id
+    `
+	rows, err := is.DB.QueryContext(context.Background(), query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []Invitation{}
+	for rows.Next() {
+		invitation := Invitation{}
+		err := rows.Scan(
+			&invitation.ID,
+			&invitation.BoardID,
+			&invitation.Email,
+			&invitation.Role,
+			&invitation.InvitedBy,
+			&invitation.Expiry,
+			&invitation.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// Get returns the pending invitation the token was mailed with.
+func (is InvitationService) Get(token string) (*Invitation, error) {
+	hash := sha256.Sum256([]byte(token))
+	query := `
+        select id, board_id, email, role, invited_by, expiry, created_at
+        from board_invitations
+        where hash = $1 and expiry > now()
+    `
+	row := is.DB.QueryRowContext(context.Background(), query, hash[:])
+	invitation := Invitation{}
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.BoardID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.InvitedBy,
+		&invitation.Expiry,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrInvitationNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &invitation, nil
+}
+
+// Delete revokes a pending invitation.
+func (is InvitationService) Delete(boardID, invitationID int64) error {
+	query := `delete from board_invitations where id = $1 and board_id = $2`
+	result, err := is.DB.ExecContext(context.Background(), query, invitationID, boardID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// Accept uses up the invitation the token was mailed with and makes the user
+// a member of the board with the role it offers. The invitation only works
+// for the user whose email address it was sent to, and since redeeming the
+// mailed token proves they own that address, it activates them as well.
+func (is InvitationService) Accept(token string, user *User) (*Board, error) {
+	tx, err := is.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	hash := sha256.Sum256([]byte(token))
+	query := `
+        delete from board_invitations
+        where hash = $1 and expiry > now() and lower(email) = lower($2)
+        returning board_id, role
+    `
+	var boardID int64
+	var role string
+	row := tx.QueryRowContext(context.Background(), query, hash[:], user.Email)
+	if err := row.Scan(&boardID, &role); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrInvitationNotFound
+		default:
+			return nil, err
+		}
+	}
+	query = `
+        insert into board_members (board_id, user_id, role)
+        values ($1, $2, $3)
+        on conflict (board_id, user_id) do nothing
+    `
+	result, err := tx.ExecContext(context.Background(), query, boardID, user.ID, role)
+	if err != nil {
+		return nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rowsAffected == 0 {
+		return nil, ErrAlreadyMember
+	}
+	query = `update users set activated = true where id = $1`
+	if _, err := tx.ExecContext(context.Background(), query, user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	user.Activated = true
+	return BoardService{DB: is.DB}.Get(user.ID, boardID)
+}
+
+// DeleteExpired deletes up to batchSize expired invitations and returns how
+// many it deleted.
+func (is InvitationService) DeleteExpired(batchSize int) (int, error) {
+	query := `
+        delete from board_invitations
+        where id in (
+            select id from board_invitations
+            where expiry < now()
+            limit $1
+            for update skip locked
+        )
+    `
+	return deleteBatch(is.DB, query, batchSize)
+}
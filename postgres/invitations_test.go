@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvitations(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	owner := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	owner.Password.Set("kishor123")
+	if err := service.User.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	invitee := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	invitee.Password.Set("bibek123")
+	if err := service.User.Create(invitee); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, owner)
+
+	member := &Invitation{BoardID: board.ID, Email: "Kishor@gmail.com", Role: RoleEditor, InvitedBy: owner.ID}
+	if err := service.Invitation.New(member, time.Hour); err != ErrAlreadyMember {
+		t.Errorf("want %v; got %v", ErrAlreadyMember, err)
+	}
+
+	first := &Invitation{BoardID: board.ID, Email: invitee.Email, Role: RoleViewer, InvitedBy: owner.ID}
+	if err := service.Invitation.New(first, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	second := &Invitation{BoardID: board.ID, Email: invitee.Email, Role: RoleEditor, InvitedBy: owner.ID}
+	if err := service.Invitation.New(second, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	invitations, err := service.Invitation.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invitations) != 1 || invitations[0].Role != RoleEditor {
+		t.Errorf("inviting again should replace the invitation, got %+v", invitations)
+	}
+	if _, err := service.Invitation.Accept(first.PlainText, invitee); err != ErrInvitationNotFound {
+		t.Errorf("replaced invitation: want %v; got %v", ErrInvitationNotFound, err)
+	}
+	if _, err := service.Invitation.Accept(second.PlainText, owner); err != ErrInvitationNotFound {
+		t.Errorf("other user: want %v; got %v", ErrInvitationNotFound, err)
+	}
+
+	shared, err := service.Invitation.Accept(second.PlainText, invitee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.ID != board.ID || shared.Role != RoleEditor {
+		t.Errorf("want board %d as %s; got %+v", board.ID, RoleEditor, shared)
+	}
+	// invitee never verified their address; the mailed token does that.
+	got, err := service.User.GetByEmail(invitee.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !invitee.Activated || !got.Activated {
+		t.Error("accepting an invitation should activate the user")
+	}
+	if _, err := service.Invitation.Accept(second.PlainText, invitee); err != ErrInvitationNotFound {
+		t.Errorf("used invitation: want %v; got %v", ErrInvitationNotFound, err)
+	}
+
+	expired := &Invitation{BoardID: board.ID, Email: "someone@gmail.com", Role: RoleViewer, InvitedBy: owner.ID}
+	if err := service.Invitation.New(expired, -time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Invitation.Get(expired.PlainText); err != ErrInvitationNotFound {
+		t.Errorf("expired invitation: want %v; got %v", ErrInvitationNotFound, err)
+	}
+	n, err := service.Invitation.DeleteExpired(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 expired invitation deleted; got %d", n)
+	}
+
+	revoked := &Invitation{BoardID: board.ID, Email: "someone@gmail.com", Role: RoleViewer, InvitedBy: owner.ID}
+	if err := service.Invitation.New(revoked, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Invitation.Delete(board.ID, revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Invitation.Delete(board.ID, revoked.ID); err != ErrInvitationNotFound {
+		t.Errorf("want %v; got %v", ErrInvitationNotFound, err)
+	}
+}
@@ -0,0 +1,224 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Roles a board member can hold, from most to least privileged. Owners
+// manage the board and its members, editors change columns and tasks and
+// viewers only read.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var (
+	ErrMemberNotFound = errors.New("board member not found")
+	ErrAlreadyMember  = errors.New("user is already a member of the board")
+	ErrLastOwner      = errors.New("a board needs at least one owner")
+)
+
+// Member is a user with access to a board.
+type Member struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasRole reports whether a member holding role may act as want.
+func HasRole(role, want string) bool {
+	rank := map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+	return rank[role] > 0 && rank[role] >= rank[want]
+}
+
+type MemberService struct {
+	DB *sql.DB
+}
+
+func (ms MemberService) GetAll(boardID int64) ([]Member, error) {
+	query := `
+        select users.id, username, email, role, board_members.created_at
+        from board_members
+        join users on users.id = board_members.user_id
+        where board_id = $1
+        order by board_members.created_at, users.id
+    `
+	rows, err := ms.DB.QueryContext(context.Background(), query, boardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		member := Member{}
+		err := rows.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Add gives the user registered with email access to the board.
+func (ms MemberService) Add(boardID int64, email, role string) (*Member, error) {
+	query := `
+        insert into board_members (board_id, user_id, role)
+        select $1, id, $3 from users
+        where email = $2
+        returning user_id, created_at
+    `
+	args := []any{boardID, email, role}
+	row := ms.DB.QueryRowContext(context.Background(), query, args...)
+	member := Member{Email: email, Role: role}
+	err := row.Scan(&member.UserID, &member.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrUserNotFound
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return nil, ErrAlreadyMember
+		default:
+			return nil, err
+		}
+	}
+	query = `select username from users where id = $1`
+	row = ms.DB.QueryRowContext(context.Background(), query, member.UserID)
+	if err := row.Scan(&member.Username); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// UpdateRole changes the role of a member. The last owner of a board cannot
+// be demoted.
+func (ms MemberService) UpdateRole(boardID, userID int64, role string) (*Member, error) {
+	tx, err := ms.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if role != RoleOwner {
+		if err := keepOwner(tx, boardID, userID); err != nil {
+			return nil, err
+		}
+	}
+	query := `
+        update board_members
+        set role = $1
+        from users
+        where board_id = $2 and user_id = $3 and users.id = user_id
+        returning user_id, username, email, role, board_members.created_at
+    `
+	args := []any{role, boardID, userID}
+	row := tx.QueryRowContext(context.Background(), query, args...)
+	member := Member{}
+	err = row.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrMemberNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// Remove takes away the user's access to the board. The last owner of a
+// board cannot be removed.
+func (ms MemberService) Remove(boardID, userID int64) error {
+	tx, err := ms.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := keepOwner(tx, boardID, userID); err != nil {
+		return err
+	}
+	query := `delete from board_members where board_id = $1 and user_id = $2`
+	result, err := tx.ExecContext(context.Background(), query, boardID, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrMemberNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// insertMember gives userID role on the board inside tx.
+func insertMember(tx *sql.Tx, boardID, userID int64, role string) error {
+	query := `insert into board_members (board_id, user_id, role) values ($1, $2, $3)`
+	_, err := tx.ExecContext(context.Background(), query, boardID, userID, role)
+	return err
+}
+
+// keepOwner returns ErrLastOwner if userID is the only owner of the board.
+// It locks the board so concurrent changes to its members cannot both pass
+// the check. Boards of an organization are always owned by its admins, so
+// they can do without owning members.
+func keepOwner(tx *sql.Tx, boardID, userID int64) error {
+	query := `select org_id is not null from boards where id = $1 for update`
+	var inOrg bool
+	err := tx.QueryRowContext(context.Background(), query, boardID).Scan(&inOrg)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrBoardNotFound
+		default:
+			return err
+		}
+	}
+	if inOrg {
+		return nil
+	}
+	query = `
+        select count(*) filter (where user_id <> $2)
+        from board_members
+        where board_id = $1 and role = 'owner'
+        having bool_or(user_id = $2)
+    `
+	var others int
+	err = tx.QueryRowContext(context.Background(), query, boardID, userID).Scan(&others)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// userID is not an owner.
+			return nil
+		default:
+			return err
+		}
+	}
+	if others == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
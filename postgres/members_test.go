@@ -0,0 +1,98 @@
+package postgres
+
+import "testing"
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want string
+		ok   bool
+	}{
+		{RoleOwner, RoleOwner, true},
+		{RoleOwner, RoleViewer, true},
+		{RoleEditor, RoleEditor, true},
+		{RoleEditor, RoleOwner, false},
+		{RoleViewer, RoleEditor, false},
+		{"", RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := HasRole(tt.role, tt.want); got != tt.ok {
+			t.Errorf("HasRole(%q, %q): want %v; got %v", tt.role, tt.want, tt.ok, got)
+		}
+	}
+}
+
+func TestMembers(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	owner := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	owner.Password.Set("kishor123")
+	if err := service.User.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	other := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	other.Password.Set("bibek123")
+	if err := service.User.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, owner)
+	if board.Role != RoleOwner {
+		t.Errorf("creator should own the board, got role %q", board.Role)
+	}
+
+	if _, err := service.Member.Add(board.ID, "nobody@gmail.com", RoleViewer); err != ErrUserNotFound {
+		t.Errorf("want %v; got %v", ErrUserNotFound, err)
+	}
+	member, err := service.Member.Add(board.ID, other.Email, RoleViewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.UserID != other.ID || member.Username != other.Username {
+		t.Errorf("want member %d; got %+v", other.ID, member)
+	}
+	if _, err := service.Member.Add(board.ID, other.Email, RoleEditor); err != ErrAlreadyMember {
+		t.Errorf("want %v; got %v", ErrAlreadyMember, err)
+	}
+	shared, err := service.Board.Get(other.ID, board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared.Role != RoleViewer {
+		t.Errorf("want role %q; got %q", RoleViewer, shared.Role)
+	}
+
+	if _, err := service.Member.UpdateRole(board.ID, owner.ID, RoleEditor); err != ErrLastOwner {
+		t.Errorf("want %v; got %v", ErrLastOwner, err)
+	}
+	if err := service.Member.Remove(board.ID, owner.ID); err != ErrLastOwner {
+		t.Errorf("want %v; got %v", ErrLastOwner, err)
+	}
+	if _, err := service.Member.UpdateRole(board.ID, other.ID, RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Member.Remove(board.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Board.Get(owner.ID, board.ID); err != ErrBoardNotFound {
+		t.Errorf("want %v; got %v", ErrBoardNotFound, err)
+	}
+
+	members, err := service.Member.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 1 || members[0].UserID != other.ID || members[0].Role != RoleOwner {
+		t.Errorf("want only %d as owner; got %+v", other.ID, members)
+	}
+	if err := service.Member.Remove(board.ID, owner.ID); err != ErrMemberNotFound {
+		t.Errorf("want %v; got %v", ErrMemberNotFound, err)
+	}
+}
@@ -0,0 +1,350 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Roles a user can hold in an organization, from most to least privileged.
+// Owners and admins own every board of the organization and manage its
+// teams; only owners manage its owners and admins. Members see the boards
+// their teams are granted.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+var (
+	ErrOrgNotFound       = errors.New("organization not found")
+	ErrOrgMemberNotFound = errors.New("organization member not found")
+	ErrAlreadyOrgMember  = errors.New("user is already a member of the organization")
+	ErrLastOrgOwner      = errors.New("an organization needs at least one owner")
+)
+
+type Org struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Role is the role in the organization of the user it was loaded for.
+	Role      string    `json:"role,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasOrgRole reports whether a member holding role may act as want.
+func HasOrgRole(role, want string) bool {
+	rank := map[string]int{OrgRoleMember: 1, OrgRoleAdmin: 2, OrgRoleOwner: 3}
+	return rank[role] > 0 && rank[role] >= rank[want]
+}
+
+type OrgService struct {
+	DB *sql.DB
+}
+
+// Create creates the organization with userID as its owner.
+func (o OrgService) Create(org *Org, userID int64) error {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `insert into orgs (name) values ($1) returning id, created_at`
+	row := tx.QueryRowContext(context.Background(), query, org.Name)
+	if err := row.Scan(&org.ID, &org.CreatedAt); err != nil {
+		return err
+	}
+	query = `insert into org_members (org_id, user_id, role) values ($1, $2, $3)`
+	_, err = tx.ExecContext(context.Background(), query, org.ID, userID, OrgRoleOwner)
+	if err != nil {
+		return err
+	}
+	org.Role = OrgRoleOwner
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetAll returns the organizations userID belongs to.
+func (o OrgService) GetAll(userID int64) ([]Org, error) {
+	query := `
+        select orgs.id, name, role, orgs.created_at
+        from orgs
+        join org_members on org_members.org_id = orgs.id
+        where org_members.user_id = $1
+        order by orgs.id
+    `
+	rows, err := o.DB.QueryContext(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []Org{}
+	for rows.Next() {
+		org := Org{}
+		if err := rows.Scan(&org.ID, &org.Name, &org.Role, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// Get returns the organization if userID belongs to it, along with the
+// user's role.
+func (o OrgService) Get(userID, orgID int64) (*Org, error) {
+	query := `
+        select orgs.id, name, role, orgs.created_at
+        from orgs
+        join org_members on org_members.org_id = orgs.id
+        where orgs.id = $1 and org_members.user_id = $2
+    `
+	row := o.DB.QueryRowContext(context.Background(), query, orgID, userID)
+	org := Org{}
+	err := row.Scan(&org.ID, &org.Name, &org.Role, &org.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrOrgNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &org, nil
+}
+
+// Delete removes the organization together with its teams and boards.
+func (o OrgService) Delete(orgID int64) error {
+	query := `delete from orgs where id = $1`
+	result, err := o.DB.ExecContext(context.Background(), query, orgID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrOrgNotFound
+	}
+	return nil
+}
+
+func (o OrgService) GetMembers(orgID int64) ([]Member, error) {
+	query := `
+        select users.id, username, email, role, org_members.created_at
+        from org_members
+        join users on users.id = org_members.user_id
+        where org_id = $1
+        order by org_members.created_at, users.id
+    `
+	rows, err := o.DB.QueryContext(context.Background(), query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		member := Member{}
+		err := rows.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddMember adds the user registered with email to the organization.
+func (o OrgService) AddMember(orgID int64, email, role string) (*Member, error) {
+	query := `
+        insert into org_members (org_id, user_id, role)
+        select $1, id, $3 from users
+        where email = $2
+        returning user_id, created_at
+    `
+	args := []any{orgID, email, role}
+	row := o.DB.QueryRowContext(context.Background(), query, args...)
+	member := Member{Email: email, Role: role}
+	err := row.Scan(&member.UserID, &member.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrUserNotFound
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return nil, ErrAlreadyOrgMember
+		default:
+			return nil, err
+		}
+	}
+	query = `select username from users where id = $1`
+	row = o.DB.QueryRowContext(context.Background(), query, member.UserID)
+	if err := row.Scan(&member.Username); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// GetMember returns the member of the organization with userID.
+func (o OrgService) GetMember(orgID, userID int64) (*Member, error) {
+	query := `
+        select users.id, username, email, role, org_members.created_at
+        from org_members
+        join users on users.id = org_members.user_id
+        where org_id = $1 and user_id = $2
+    `
+	row := o.DB.QueryRowContext(context.Background(), query, orgID, userID)
+	member := Member{}
+	err := row.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrOrgMemberNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &member, nil
+}
+
+// UpdateMemberRole changes the role of a member. The last owner of an
+// organization cannot be demoted.
+func (o OrgService) UpdateMemberRole(orgID, userID int64, role string) (*Member, error) {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if role != OrgRoleOwner {
+		if err := keepOrgOwner(tx, orgID, userID); err != nil {
+			return nil, err
+		}
+	}
+	query := `
+        update org_members
+        set role = $1
+        from users
+        where org_id = $2 and user_id = $3 and users.id = user_id
+        returning user_id, username, email, role, org_members.created_at
+    `
+	args := []any{role, orgID, userID}
+	row := tx.QueryRowContext(context.Background(), query, args...)
+	member := Member{}
+	err = row.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrOrgMemberNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// RemoveMember takes the user out of the organization, its teams and the
+// member lists of its boards, which takes away all their access to the
+// organization's boards. The last owner of an organization cannot be
+// removed.
+func (o OrgService) RemoveMember(orgID, userID int64) error {
+	tx, err := o.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := keepOrgOwner(tx, orgID, userID); err != nil {
+		return err
+	}
+	query := `delete from org_members where org_id = $1 and user_id = $2`
+	result, err := tx.ExecContext(context.Background(), query, orgID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrOrgMemberNotFound
+	}
+	query = `
+        delete from team_members
+        using teams
+        where teams.id = team_members.team_id and teams.org_id = $1 and team_members.user_id = $2
+    `
+	if _, err := tx.ExecContext(context.Background(), query, orgID, userID); err != nil {
+		return err
+	}
+	// Creators are direct owners of the org boards they made; that goes with
+	// the membership too.
+	query = `
+        delete from board_members
+        using boards
+        where boards.id = board_members.board_id and boards.org_id = $1 and board_members.user_id = $2
+    `
+	if _, err := tx.ExecContext(context.Background(), query, orgID, userID); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// keepOrgOwner returns ErrLastOrgOwner if userID is the only owner of the
+// organization. Like keepOwner it locks the organization first.
+func keepOrgOwner(tx *sql.Tx, orgID, userID int64) error {
+	query := `select id from orgs where id = $1 for update`
+	var id int64
+	err := tx.QueryRowContext(context.Background(), query, orgID).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrOrgNotFound
+		default:
+			return err
+		}
+	}
+	query = `
+        select count(*) filter (where user_id <> $2)
+        from org_members
+        where org_id = $1 and role = 'owner'
+        having bool_or(user_id = $2)
+    `
+	var others int
+	err = tx.QueryRowContext(context.Background(), query, orgID, userID).Scan(&others)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// userID is not an owner.
+			return nil
+		default:
+			return err
+		}
+	}
+	if others == 0 {
+		return ErrLastOrgOwner
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import "testing"
+
+func TestHasOrgRole(t *testing.T) {
+	tests := []struct {
+		role string
+		want string
+		ok   bool
+	}{
+		{OrgRoleOwner, OrgRoleAdmin, true},
+		{OrgRoleAdmin, OrgRoleAdmin, true},
+		{OrgRoleAdmin, OrgRoleOwner, false},
+		{OrgRoleMember, OrgRoleAdmin, false},
+		{"", OrgRoleMember, false},
+	}
+	for _, tt := range tests {
+		if got := HasOrgRole(tt.role, tt.want); got != tt.ok {
+			t.Errorf("HasOrgRole(%q, %q): want %v; got %v", tt.role, tt.want, tt.ok, got)
+		}
+	}
+}
+
+func TestOrgBoardAccess(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	owner := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	owner.Password.Set("kishor123")
+	if err := service.User.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	user.Password.Set("bibek123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	org := &Org{Name: "Acme"}
+	if err := service.Org.Create(org, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	board := &Board{UserID: owner.ID, OrgID: &org.ID, Name: "Roadmap"}
+	if err := service.Board.Create(board); err != nil {
+		t.Fatal(err)
+	}
+	team := &Team{OrgID: org.ID, Name: "Engineering"}
+	if err := service.Team.Create(team); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Team.AddMember(team, user.ID); err != ErrOrgMemberNotFound {
+		t.Errorf("want %v; got %v", ErrOrgMemberNotFound, err)
+	}
+	if _, err := service.Org.AddMember(org.ID, user.Email, OrgRoleMember); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Board.Get(user.ID, board.ID); err != ErrBoardNotFound {
+		t.Errorf("org member without a team: want %v; got %v", ErrBoardNotFound, err)
+	}
+
+	if err := service.Team.AddMember(team, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Team.GrantBoard(team, board.ID, RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	got, err := service.Board.Get(user.ID, board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Role != RoleViewer {
+		t.Errorf("team grant: want %q; got %q", RoleViewer, got.Role)
+	}
+
+	if _, err := service.Org.UpdateMemberRole(org.ID, user.ID, OrgRoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	got, err = service.Board.Get(user.ID, board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Role != RoleOwner {
+		t.Errorf("org admin: want %q; got %q", RoleOwner, got.Role)
+	}
+	created := &Board{UserID: user.ID, OrgID: &org.ID, Name: "Hiring"}
+	if err := service.Board.Create(created); err != nil {
+		t.Fatal(err)
+	}
+
+	// The creator can leave the board since org owners keep owning it.
+	if err := service.Member.Remove(board.ID, owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Org.UpdateMemberRole(org.ID, owner.ID, OrgRoleMember); err != ErrLastOrgOwner {
+		t.Errorf("want %v; got %v", ErrLastOrgOwner, err)
+	}
+
+	if err := service.Org.RemoveMember(org.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Board.Get(user.ID, board.ID); err != ErrBoardNotFound {
+		t.Errorf("removed from org: want %v; got %v", ErrBoardNotFound, err)
+	}
+	if _, err := service.Board.Get(user.ID, created.ID); err != ErrBoardNotFound {
+		t.Errorf("removed from org, board they created: want %v; got %v", ErrBoardNotFound, err)
+	}
+	members, err := service.Team.GetMembers(team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 0 {
+		t.Errorf("removing from the org should empty their teams, got %+v", members)
+	}
+
+	if err := service.User.Delete(owner.ID); err != ErrLastOrgOwner {
+		t.Errorf("want %v; got %v", ErrLastOrgOwner, err)
+	}
+	if _, err := service.Org.AddMember(org.ID, user.Email, OrgRoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.User.Delete(owner.ID); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow(`select count(*) from boards where id = $1`, board.ID).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("org board should outlive its creator")
+	}
+}
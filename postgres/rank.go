@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"errors"
+	"strings"
+)
+
+// Tasks are ordered inside a column by a rank key: a base-62 fraction
+// written without its leading "0." whose digits sort in the same order under
+// byte-wise (collate "C") comparison as their numeric value. A key can
+// always be found between two others, so moving a task rewrites only that
+// task's row.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var errInvalidRank = errors.New("invalid rank key")
+
+// rankBetween returns a key that sorts strictly between a and b. An empty a
+// means the start of the column and an empty b the end of it.
+func rankBetween(a, b string) (string, error) {
+	if !validRank(a) || !validRank(b) {
+		return "", errInvalidRank
+	}
+	if a != "" && b != "" && a >= b {
+		return "", errInvalidRank
+	}
+	return rankMidpoint(a, b), nil
+}
+
+func validRank(key string) bool {
+	if strings.HasSuffix(key, "0") {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(rankDigits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func rankMidpoint(a, b string) string {
+	if b != "" {
+		// Copy the common prefix, treating a as padded with zeros.
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + rankMidpoint(rest, b[n:])
+		}
+	}
+
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(rankDigits, a[0])
+	}
+	hi := len(rankDigits)
+	if b != "" {
+		hi = strings.IndexByte(rankDigits, b[0])
+	}
+	if hi-lo > 1 {
+		return string(rankDigits[(lo+hi)/2])
+	}
+	// The first digits are consecutive. If b has more digits its first digit
+	// alone already sorts between a and b; otherwise keep a's first digit and
+	// find a key above the rest of a.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(rankDigits[lo]) + rankMidpoint(rest, "")
+}
+
+func rankDigitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return rankDigits[0]
+}
+
+// spreadRanks returns n ascending keys spaced evenly over the whole key
+// space using as few digits as possible. It is used to compact a column
+// whose keys have grown long.
+func spreadRanks(n int) []string {
+	base := len(rankDigits)
+	width, space := 1, base
+	for space < n+1 {
+		width++
+		space *= base
+	}
+
+	keys := make([]string, n)
+	buf := make([]byte, width)
+	for i := range keys {
+		v := (i + 1) * space / (n + 1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = rankDigits[v%base]
+			v /= base
+		}
+		keys[i] = strings.TrimRight(string(buf), rankDigits[:1])
+	}
+	return keys
+}
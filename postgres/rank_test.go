@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+	}{
+		{name: "empty column", a: "", b: ""},
+		{name: "before first", a: "", b: "V"},
+		{name: "before smallest digit", a: "", b: "1"},
+		{name: "after last", a: "z", b: ""},
+		{name: "consecutive digits", a: "V", b: "W"},
+		{name: "shared prefix", a: "V1", b: "V2"},
+		{name: "prefix of other", a: "V", b: "V1"},
+		{name: "padded with zeros", a: "1", b: "101"},
+	}
+	for _, tt := range tests {
+		got, err := rankBetween(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !validRank(got) || got == "" {
+			t.Errorf("%s: got invalid key %q", tt.name, got)
+		}
+		if tt.a != "" && !(tt.a < got) {
+			t.Errorf("%s: want %q < %q", tt.name, tt.a, got)
+		}
+		if tt.b != "" && !(got < tt.b) {
+			t.Errorf("%s: want %q < %q", tt.name, got, tt.b)
+		}
+	}
+}
+
+func TestRankBetweenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+	}{
+		{name: "equal keys", a: "V", b: "V"},
+		{name: "reversed keys", a: "W", b: "V"},
+		{name: "trailing zero", a: "V0", b: ""},
+		{name: "bad digit", a: "", b: "V-"},
+	}
+	for _, tt := range tests {
+		if _, err := rankBetween(tt.a, tt.b); err != errInvalidRank {
+			t.Errorf("%s: want %v; got %v", tt.name, errInvalidRank, err)
+		}
+	}
+}
+
+func TestRankRandomInserts(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		idx := r.Intn(len(keys) + 1)
+		before, after := "", ""
+		if idx > 0 {
+			before = keys[idx-1]
+		}
+		if idx < len(keys) {
+			after = keys[idx]
+		}
+		key, err := rankBetween(before, after)
+		if err != nil {
+			t.Fatalf("insert %d between %q and %q: %v", i, before, after, err)
+		}
+		keys = append(keys[:idx], append([]string{key}, keys[idx:]...)...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatal("keys are not in order")
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] == keys[i] {
+			t.Fatalf("duplicate key %q", keys[i])
+		}
+	}
+}
+
+func TestSpreadRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 61, 62, 100, 5000} {
+		keys := spreadRanks(n)
+		if len(keys) != n {
+			t.Fatalf("n = %d: got %d keys", n, len(keys))
+		}
+		for i, key := range keys {
+			if !validRank(key) || key == "" {
+				t.Fatalf("n = %d: invalid key %q", n, key)
+			}
+			if i > 0 && keys[i-1] >= key {
+				t.Fatalf("n = %d: keys %q and %q out of order", n, keys[i-1], key)
+			}
+		}
+	}
+}
@@ -3,16 +3,32 @@ package postgres
 import "database/sql"
 
 type Service struct {
-	User  UserService
-	Token TokenService
-	Task  TaskService
+	User       UserService
+	Token      TokenService
+	Task       TaskService
+	Board      BoardService
+	Column     ColumnService
+	Throttle   ThrottleService
+	Identity   IdentityService
+	Member     MemberService
+	Invitation InvitationService
+	Org        OrgService
+	Team       TeamService
 }
 
 func NewService(db *sql.DB) Service {
 	s := Service{
-		User:  UserService{DB: db},
-		Token: TokenService{DB: db},
-		Task:  TaskService{DB: db},
+		User:       UserService{DB: db},
+		Token:      TokenService{DB: db},
+		Task:       TaskService{DB: db},
+		Board:      BoardService{DB: db},
+		Column:     ColumnService{DB: db},
+		Throttle:   ThrottleService{DB: db},
+		Identity:   IdentityService{DB: db},
+		Member:     MemberService{DB: db},
+		Invitation: InvitationService{DB: db},
+		Org:        OrgService{DB: db},
+		Team:       TeamService{DB: db},
 	}
 	return s
 }
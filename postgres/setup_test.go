@@ -36,3 +36,36 @@ func newTestDB(t *testing.T) (*sql.DB, func()) {
 		db.Close()
 	}
 }
+
+func newTestBoard(t *testing.T, service Service, user *User) *Board {
+	board := &Board{
+		UserID: user.ID,
+		Name:   "Test Board",
+	}
+	if err := service.Board.Create(board); err != nil {
+		t.Fatal(err)
+	}
+	return board
+}
+
+func byName(columns []ColumnTasks) map[string]ColumnTasks {
+	m := map[string]ColumnTasks{}
+	for _, c := range columns {
+		m[c.Name] = c
+	}
+	return m
+}
+
+func columnID(t *testing.T, service Service, boardID int64, name string) int64 {
+	columns, err := service.Column.GetAll(boardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range columns {
+		if c.Name == name {
+			return c.ID
+		}
+	}
+	t.Fatalf("board %d has no column %q", boardID, name)
+	return 0
+}
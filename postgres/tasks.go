@@ -9,125 +9,551 @@ import (
 	"github.com/lib/pq"
 )
 
-var ErrInvalidData = errors.New("invalid task id or source index or destination index")
-
-var categories = []string{"TODO", "IN PROGRESS", "TESTING", "DONE"}
+var (
+	ErrInvalidData  = errors.New("invalid task id or source index or destination index")
+	ErrTaskNotFound = errors.New("task not found")
+)
 
 type Task struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id,omitempty"`
-	Category  string    `json:"category"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int64      `json:"id"`
+	BoardID    int64      `json:"board_id,omitempty"`
+	ColumnID   int64      `json:"column_id"`
+	UserID     int64      `json:"user_id,omitempty"`
+	Content    string     `json:"content"`
+	Assignees  []Assignee `json:"assignees"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// ColumnTasks is a column together with its tasks in board order.
+type ColumnTasks struct {
+	Column
+	Tasks []Task `json:"tasks"`
 }
 
 type TaskService struct {
 	DB *sql.DB
 }
 
-func (ts TaskService) GetAll(userID int64) (map[string][]Task, error) {
-	query := `
-        select x.id, tasks.category, content, created_at 
-        from taskorder, unnest(value)
-        with ordinality as x(id)
-        join tasks on tasks.id = x.id where tasks.user_id = $1;
+func (ts TaskService) GetAll(boardID int64) ([]ColumnTasks, error) {
+	return ts.getAll(boardID, 0)
+}
+
+// GetAllAssigned is like GetAll but leaves out the tasks that are not
+// assigned to userID. Every column is still returned.
+func (ts TaskService) GetAllAssigned(boardID, userID int64) ([]ColumnTasks, error) {
+	return ts.getAll(boardID, userID)
+}
+
+// getAll returns the board's columns with their unarchived tasks, only the
+// ones assigned to assigneeID unless it is zero.
+func (ts TaskService) getAll(boardID, assigneeID int64) ([]ColumnTasks, error) {
+	queryColumns := `
+        select id, board_id, name, color, position, coalesce(wip_limit, 0), created_at
+        from board_columns
+        where board_id = $1
+        order by position
     `
-	rows, err := ts.DB.Query(query, userID)
+	rows, err := ts.DB.Query(queryColumns, boardID)
 	if err != nil {
-		return map[string][]Task{}, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []ColumnTasks{}
+	index := map[int64]int{}
+	for rows.Next() {
+		c := ColumnTasks{Tasks: []Task{}}
+		err := rows.Scan(&c.ID, &c.BoardID, &c.Name, &c.Color, &c.Position, &c.WIPLimit, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		index[c.ID] = len(columns)
+		columns = append(columns, c)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
 	}
 
-	tasks := map[string][]Task{}
-	for _, val := range categories {
-		tasks[val] = []Task{}
+	queryTasks := `
+        select tasks.id, tasks.column_id, content, tasks.created_at,` + assigneeColumns + `
+        from tasks
+        join board_columns on board_columns.id = tasks.column_id
+        where tasks.board_id = $1 and archived_at is null
+        and ($2 = 0 or exists (
+            select 1 from task_assignees
+            where task_id = tasks.id and user_id = $2 and` + assignedOnBoard + `
+        ))
+        order by board_columns.position, rank
+    `
+	rows, err = ts.DB.Query(queryTasks, boardID, assigneeID)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		task := Task{}
-		rows.Scan(&task.ID, &task.Category, &task.Content, &task.CreatedAt)
-		_, ok := tasks[task.Category]
-		if !ok {
-			tasks[task.Category] = []Task{task}
-			continue
+		var ids []int64
+		var usernames []string
+		err := rows.Scan(
+			&task.ID,
+			&task.ColumnID,
+			&task.Content,
+			&task.CreatedAt,
+			pq.Array(&ids),
+			pq.Array(&usernames),
+		)
+		if err != nil {
+			return nil, err
 		}
-		tasks[task.Category] = append(tasks[task.Category], task)
+		task.Assignees = newAssignees(ids, usernames)
+		i := index[task.ColumnID]
+		columns[i].Tasks = append(columns[i].Tasks, task)
 	}
 	if err := rows.Close(); err != nil {
-		return map[string][]Task{}, err
+		return nil, err
 	}
-	return tasks, nil
+	return columns, nil
 }
 
-func (ts TaskService) Insert(task *Task) error {
-	queryInsertTask := `
-        insert into tasks (user_id, content)
-        values ($1, $2) returning id, category, created_at
+// ExportedTask is a task together with its place in its column.
+type ExportedTask struct {
+	Task
+	// Position is the task's zero-based index in its column, or nil for an
+	// archived task.
+	Position *int `json:"position"`
+}
+
+// Export calls fn with every task, archived ones included, on the boards
+// the user owns, ordered by board, column and position. Rows are read one at
+// a time, so the caller can stream them.
+func (ts TaskService) Export(userID int64, fn func(ExportedTask) error) error {
+	query := `
+        select tasks.id, tasks.board_id, tasks.column_id, coalesce(tasks.user_id, 0), content,
+        tasks.created_at, archived_at,` + assigneeColumns + `,
+        case when archived_at is null then
+            row_number() over (
+                partition by tasks.column_id, archived_at is null
+                order by rank
+            ) - 1
+        end as position
+        from tasks
+        join boards on boards.id = tasks.board_id
+        join board_columns on board_columns.id = tasks.column_id
+        join board_members on board_members.board_id = tasks.board_id
+        where board_members.user_id = $1 and board_members.role = 'owner' and boards.org_id is null
+        order by tasks.board_id, board_columns.position, archived_at nulls first, rank
+    `
+	rows, err := ts.DB.QueryContext(context.Background(), query, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		task := ExportedTask{}
+		var ids []int64
+		var usernames []string
+		err := rows.Scan(
+			&task.ID,
+			&task.BoardID,
+			&task.ColumnID,
+			&task.UserID,
+			&task.Content,
+			&task.CreatedAt,
+			&task.ArchivedAt,
+			pq.Array(&ids),
+			pq.Array(&usernames),
+			&task.Position,
+		)
+		if err != nil {
+			return err
+		}
+		task.Assignees = newAssignees(ids, usernames)
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// columnOrder returns the ids and rank keys of the unarchived tasks in a
+// column, in board order. The column must be locked with lockColumns.
+func columnOrder(tx *sql.Tx, columnID int64) ([]int64, []string, error) {
+	query := `
+        select id, rank from tasks
+        where column_id = $1 and archived_at is null
+        order by rank
+    `
+	rows, err := tx.QueryContext(context.Background(), query, columnID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	ranks := []string{}
+	for rows.Next() {
+		var id int64
+		var rank string
+		if err := rows.Scan(&id, &rank); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		ranks = append(ranks, rank)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return ids, ranks, nil
+}
+
+// lastRank returns a rank key that sorts after every unarchived task in the
+// column. The column must be locked.
+func lastRank(tx *sql.Tx, columnID int64) (string, error) {
+	query := `
+        select coalesce(max(rank), '') from tasks
+        where column_id = $1 and archived_at is null
+    `
+	var last string
+	row := tx.QueryRowContext(context.Background(), query, columnID)
+	if err := row.Scan(&last); err != nil {
+		return "", err
+	}
+	return rankBetween(last, "")
+}
+
+// rankAt returns the rank key for a task placed at index among ranks, the
+// ordered keys of the column without that task.
+func rankAt(ranks []string, index int64) (string, error) {
+	before, after := "", ""
+	if index > 0 {
+		before = ranks[index-1]
+	}
+	if index < int64(len(ranks)) {
+		after = ranks[index]
+	}
+	return rankBetween(before, after)
+}
+
+// Insert adds the task to the end of task.ColumnID, or of the board's first
+// column when no column is given. The column's WIP limit is enforced unless
+// overrideWIPLimit is set.
+func (ts TaskService) Insert(board *Board, task *Task, overrideWIPLimit bool) error {
+	queryColumn := `
+        select id from board_columns
+        where board_id = $1 and ($2 = 0 or id = $2)
+        order by position
+        limit 1
     `
-	args := []any{task.UserID, task.Content}
 	tx, err := ts.DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	task.BoardID = board.ID
+	columnRow := tx.QueryRowContext(context.Background(), queryColumn, task.BoardID, task.ColumnID)
+	if err := columnRow.Scan(&task.ColumnID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrColumnNotFound
+		default:
+			return err
+		}
+	}
+	err = checkWIPLimit(tx, task.ColumnID)
+	if errors.Is(err, ErrWIPLimitExceeded) && overrideWIPLimit {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rank, err := lastRank(tx, task.ColumnID)
+	if err != nil {
+		return err
+	}
+
+	queryInsertTask := `
+        insert into tasks (board_id, column_id, user_id, content, rank)
+        values ($1, $2, $3, $4, $5) returning id, created_at
+    `
+	args := []any{task.BoardID, task.ColumnID, task.UserID, task.Content, rank}
 	taskRow := tx.QueryRowContext(context.Background(), queryInsertTask, args...)
-	err = taskRow.Scan(&task.ID, &task.Category, &task.CreatedAt)
+	err = taskRow.Scan(&task.ID, &task.CreatedAt)
 	if err != nil {
 		return err
 	}
-	queryInsertOrder := `
-        update taskorder set value = array_append(value, $1)
-        where user_id = $2 and category = 'TODO';
+	task.Assignees = []Assignee{}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
+
+func (ts TaskService) Update(board *Board, task *Task) error {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	query := `
+        update tasks
+        set content = $1
+        where id = $2 and board_id = $3
+        returning column_id, coalesce(user_id, 0), created_at,` + assigneeColumns + `
+    `
+	args := []any{task.Content, task.ID, board.ID}
+	row := tx.QueryRowContext(context.Background(), query, args...)
+	var ids []int64
+	var usernames []string
+	err = row.Scan(&task.ColumnID, &task.UserID, &task.CreatedAt, pq.Array(&ids), pq.Array(&usernames))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrTaskNotFound
+		default:
+			return err
+		}
+	}
+	task.BoardID = board.ID
+	task.Assignees = newAssignees(ids, usernames)
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
+}
+
+func (ts TaskService) Delete(board *Board, taskID int64) error {
+	query := `
+        delete from tasks
+        where id = $1 and board_id = $2
+    `
+	return ts.changeTask(board, query, taskID)
+}
+
+func (ts TaskService) Archive(board *Board, taskID int64) error {
+	query := `
+        update tasks
+        set archived_at = now()
+        where id = $1 and board_id = $2 and archived_at is null
     `
-	_, err = tx.ExecContext(context.Background(), queryInsertOrder, task.ID, task.UserID)
+	return ts.changeTask(board, query, taskID)
+}
+
+// changeTask runs query, which takes the task and board ids, as a versioned
+// change to the board. It reports ErrTaskNotFound when no row was affected.
+func (ts TaskService) changeTask(board *Board, query string, taskID int64) error {
+	tx, err := ts.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(context.Background(), query, taskID, board.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTaskNotFound
+	}
 
 	if err := tx.Commit(); err != nil {
 		return err
 	}
+	board.Version = version
+	return nil
+}
 
+// Unarchive puts an archived task back at the end of the column it was
+// archived from. The column's WIP limit is enforced unless overrideWIPLimit
+// is set.
+func (ts TaskService) Unarchive(board *Board, taskID int64, overrideWIPLimit bool) error {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+
+	queryTask := `
+        select column_id from tasks
+        where id = $1 and board_id = $2 and archived_at is not null
+        for update
+    `
+	var columnID int64
+	row := tx.QueryRowContext(context.Background(), queryTask, taskID, board.ID)
+	if err := row.Scan(&columnID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrTaskNotFound
+		default:
+			return err
+		}
+	}
+
+	if err := lockColumns(tx, board.ID, columnID); err != nil {
+		return err
+	}
+	err = checkWIPLimit(tx, columnID)
+	if errors.Is(err, ErrWIPLimitExceeded) && overrideWIPLimit {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	rank, err := lastRank(tx, columnID)
+	if err != nil {
+		return err
+	}
+	queryUnarchiveTask := `
+        update tasks
+        set archived_at = null, rank = $1
+        where id = $2
+    `
+	_, err = tx.ExecContext(context.Background(), queryUnarchiveTask, rank, taskID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
 	return nil
 }
 
-func (ts TaskService) SortTaskInSameCategory(userID, taskID, sourceIndex, destinationIndex int64, category string) error {
+// GetArchived returns one page of archived tasks, most recently archived
+// first, together with the total number of archived tasks.
+func (ts TaskService) GetArchived(boardID int64, page, pageSize int) ([]Task, int, error) {
 	query := `
-        select value from taskorder
-        where user_id = $1 and category = $2
+        select count(*) over(), id, column_id, content, created_at, archived_at,` + assigneeColumns + `
+        from tasks
+        where board_id = $1 and archived_at is not null
+        order by archived_at desc, id desc
+        limit $2 offset $3
     `
-	row := ts.DB.QueryRow(query, userID, category)
-	ids := []int64{}
-	if err := row.Scan(pq.Array(&ids)); err != nil {
+	args := []any{boardID, pageSize, (page - 1) * pageSize}
+	rows, err := ts.DB.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	total := 0
+	tasks := []Task{}
+	for rows.Next() {
+		task := Task{}
+		var ids []int64
+		var usernames []string
+		err := rows.Scan(
+			&total,
+			&task.ID,
+			&task.ColumnID,
+			&task.Content,
+			&task.CreatedAt,
+			&task.ArchivedAt,
+			pq.Array(&ids),
+			pq.Array(&usernames),
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		task.Assignees = newAssignees(ids, usernames)
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// SortTaskInSameCategory moves the task from sourceIndex to
+// destinationIndex inside its column by giving it a rank key between its new
+// neighbours.
+func (ts TaskService) SortTaskInSameCategory(board *Board, taskID, sourceIndex, destinationIndex, columnID int64) error {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	if err := lockColumns(tx, board.ID, columnID); err != nil {
+		return err
+	}
+	ids, ranks, err := columnOrder(tx, columnID)
+	if err != nil {
 		return err
 	}
-	// TODO: Refactor
 	idx, ok := taskIdInArray(taskID, ids)
 	if !ok || idx != sourceIndex || destinationIndex > int64(len(ids)-1) {
 		return ErrInvalidData
 	}
-	move(taskID, sourceIndex, destinationIndex, ids)
+	ranks = append(ranks[:idx], ranks[idx+1:]...)
+	rank, err := rankAt(ranks, destinationIndex)
+	if err != nil {
+		return err
+	}
+
 	queryUpdate := `
-        update taskorder
-        set value = $1
-        where user_id = $2 and category = $3
+        update tasks
+        set rank = $1
+        where id = $2
     `
-	args := []any{pq.Array(ids), userID, category}
-	_, err := ts.DB.Exec(queryUpdate, args...)
-	return err
+	_, err = tx.Exec(queryUpdate, rank, taskID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	board.Version = version
+	return nil
 }
 
-func move(taskID, sourceIndex, destinationIndex int64, ids []int64) {
+func move(id, sourceIndex, destinationIndex int64, ids []int64) {
 	if sourceIndex < destinationIndex {
 		for i := sourceIndex; i < destinationIndex; i++ {
 			ids[i] = ids[i+1]
 		}
-		ids[destinationIndex] = taskID
+		ids[destinationIndex] = id
 		return
 	} else {
 		for i := sourceIndex; i > destinationIndex; i-- {
 			ids[i] = ids[i-1]
 		}
-		ids[destinationIndex] = taskID
+		ids[destinationIndex] = id
 		return
 	}
 }
@@ -142,23 +568,26 @@ func taskIdInArray(taskID int64, ids []int64) (int64, bool) {
 }
 
 func (ts TaskService) SortTaskInDifferentCategory(
-	userID, taskID, sourceIndex, destinationIndex int64,
-	sourceCategory, destinationCategory string,
+	board *Board,
+	taskID, sourceIndex, destinationIndex int64,
+	sourceColumnID, destinationColumnID int64,
+	overrideWIPLimit bool,
 ) error {
-	query := `
-        select value from taskorder
-        where user_id = $1 and category = $2
-    `
-	args := []any{userID, sourceCategory}
 	tx, err := ts.DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	row := tx.QueryRow(query, args...)
-	sourceIDs := []int64{}
-	if err := row.Scan(pq.Array(&sourceIDs)); err != nil {
+	version, err := bumpVersion(tx, board)
+	if err != nil {
+		return err
+	}
+	if err := lockColumns(tx, board.ID, sourceColumnID, destinationColumnID); err != nil {
+		return err
+	}
+	sourceIDs, _, err := columnOrder(tx, sourceColumnID)
+	if err != nil {
 		return err
 	}
 	idx, ok := taskIdInArray(taskID, sourceIDs)
@@ -166,51 +595,118 @@ func (ts TaskService) SortTaskInDifferentCategory(
 		return ErrInvalidData
 	}
 
-	newSourceIDs := []int64{}
-	newSourceIDs = append(newSourceIDs, sourceIDs[:idx]...)
-	newSourceIDs = append(newSourceIDs, sourceIDs[idx+1:]...)
-	queryUpdate := `
-        update taskorder
-        set value = $1
-        where user_id = $2 and category = $3
+	_, destinationRanks, err := columnOrder(tx, destinationColumnID)
+	if err != nil {
+		return err
+	}
+	if int(destinationIndex) > len(destinationRanks) {
+		return ErrInvalidData
+	}
+	err = checkWIPLimit(tx, destinationColumnID)
+	if errors.Is(err, ErrWIPLimitExceeded) && overrideWIPLimit {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	rank, err := rankAt(destinationRanks, destinationIndex)
+	if err != nil {
+		return err
+	}
+
+	queryUpdateTasks := `
+        update tasks
+        set column_id = $1, rank = $2
+        where id = $3 and board_id = $4
     `
-	argsQueryUpdateSource := []any{pq.Array(&newSourceIDs), userID, sourceCategory}
-	_, err = tx.Exec(queryUpdate, argsQueryUpdateSource...)
+	_, err = tx.Exec(queryUpdateTasks, destinationColumnID, rank, taskID, board.ID)
 	if err != nil {
 		return err
 	}
 
-	argsQueryDestination := []any{userID, destinationCategory}
-	destinationRow := tx.QueryRow(query, argsQueryDestination...)
-	destinationIDs := []int64{}
-	if err := destinationRow.Scan(pq.Array(&destinationIDs)); err != nil {
+	if err := tx.Commit(); err != nil {
 		return err
 	}
-	if int(destinationIndex) > len(destinationIDs) {
-		return ErrInvalidData
+	board.Version = version
+	return nil
+}
+
+// Rebalance rewrites the rank keys of every column holding a key longer than
+// maxLength with short, evenly spaced keys. It returns how many columns were
+// rewritten.
+func (ts TaskService) Rebalance(maxLength int) (int, error) {
+	query := `
+        select distinct column_id from tasks
+        where archived_at is null and length(rank) > $1
+    `
+	rows, err := ts.DB.QueryContext(context.Background(), query, maxLength)
+	if err != nil {
+		return 0, err
 	}
-	if int(destinationIndex) == len(destinationIDs) {
-		destinationIDs = append(destinationIDs, taskID)
-	} else {
-		newDestinationIDs := []int64{}
-		newDestinationIDs = append(newDestinationIDs, destinationIDs...)
-		destinationIDs = destinationIDs[:destinationIndex]
-		destinationIDs = append(destinationIDs, taskID)
-		destinationIDs = append(destinationIDs, newDestinationIDs[destinationIndex:]...)
+	defer rows.Close()
+
+	columnIDs := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return 0, err
+		}
+		columnIDs = append(columnIDs, id)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+
+	for _, columnID := range columnIDs {
+		if err := ts.rebalanceColumn(columnID); err != nil {
+			return 0, err
+		}
 	}
+	return len(columnIDs), nil
+}
 
-	argsQueryUpdateDestination := []any{pq.Array(&destinationIDs), userID, destinationCategory}
-	_, err = tx.Exec(queryUpdate, argsQueryUpdateDestination...)
+func (ts TaskService) rebalanceColumn(columnID int64) error {
+	tx, err := ts.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	queryUpdateTasks := `
+	queryColumn := `
+        select board_id from board_columns
+        where id = $1
+    `
+	var boardID int64
+	row := tx.QueryRowContext(context.Background(), queryColumn, columnID)
+	if err := row.Scan(&boardID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// The column was deleted since it was picked.
+			return nil
+		default:
+			return err
+		}
+	}
+	err = lockColumns(tx, boardID, columnID)
+	if errors.Is(err, ErrColumnNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ids, _, err := columnOrder(tx, columnID)
+	if err != nil {
+		return err
+	}
+
+	queryUpdate := `
         update tasks
-        set category = $1
-        where id = $2
+        set rank = x.rank
+        from unnest($1::bigint[], $2::text[]) as x(id, rank)
+        where tasks.id = x.id
     `
-	_, err = tx.Exec(queryUpdateTasks, destinationCategory, taskID)
+	args := []any{pq.Array(ids), pq.Array(spreadRanks(len(ids)))}
+	_, err = tx.ExecContext(context.Background(), queryUpdate, args...)
 	if err != nil {
 		return err
 	}
@@ -1,7 +1,10 @@
 package postgres
 
 import (
+	"errors"
+	"math/rand"
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -19,22 +22,206 @@ func TestInsertTask(t *testing.T) {
 	if err := service.User.Create(user); err != nil {
 		t.Fatal(err)
 	}
+	board := newTestBoard(t, service, user)
 
 	task := &Task{
+		BoardID: board.ID,
 		UserID:  user.ID,
 		Content: "Write Some Tests",
 	}
-	if err := service.Task.Insert(task); err != nil {
+	if err := service.Task.Insert(board, task, false); err != nil {
 		t.Error(err)
 	}
-	if task.Category != "TODO" {
-		t.Errorf("newly inserted task should have category TODO got = %s", task.Category)
+	if todo := columnID(t, service, board.ID, "TODO"); task.ColumnID != todo {
+		t.Errorf("newly inserted task should be in column TODO (%d) got = %d", todo, task.ColumnID)
 	}
 	if task.ID <= 0 {
 		t.Errorf("task id should be > 0, got = %d", task.ID)
 	}
 }
 
+func TestUpdateTask(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	owner := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	owner.Password.Set("kishor123")
+	if err := service.User.Create(owner); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, owner)
+	other := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	other.Password.Set("bibek123")
+	if err := service.User.Create(other); err != nil {
+		t.Fatal(err)
+	}
+
+	task := &Task{
+		BoardID: board.ID,
+		UserID:  owner.ID,
+		Content: "Write Some Tsets",
+	}
+	if err := service.Task.Insert(board, task, false); err != nil {
+		t.Fatal(err)
+	}
+
+	otherBoard := newTestBoard(t, service, other)
+	notOwned := &Task{
+		ID:      task.ID,
+		BoardID: otherBoard.ID,
+		Content: "Hijacked",
+	}
+	if err := service.Task.Update(otherBoard, notOwned); err != ErrTaskNotFound {
+		t.Errorf("want %v; got %v", ErrTaskNotFound, err)
+	}
+
+	task.Content = "Write Some Tests"
+	if err := service.Task.Update(board, task); err != nil {
+		t.Fatal(err)
+	}
+	allTasks, err := service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byName(allTasks)["TODO"].Tasks
+	if len(got) != 1 || got[0].Content != "Write Some Tests" {
+		t.Errorf("update task failed, got = %v", got)
+	}
+}
+
+func TestDeleteTask(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+
+	tasks := []*Task{
+		{BoardID: board.ID, UserID: user.ID, Content: "A"},
+		{BoardID: board.ID, UserID: user.ID, Content: "B"},
+		{BoardID: board.ID, UserID: user.ID, Content: "C"},
+	}
+	for _, task := range tasks {
+		if err := service.Task.Insert(board, task, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := service.Task.Delete(board, tasks[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Task.Delete(board, tasks[1].ID); err != ErrTaskNotFound {
+		t.Errorf("want %v; got %v", ErrTaskNotFound, err)
+	}
+
+	allTasks, err := service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"A", "C"}
+	got := []string{}
+	for _, t := range byName(allTasks)["TODO"].Tasks {
+		got = append(got, t.Content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("delete task failed, got = %v, want = %v", got, want)
+	}
+
+	// The remaining tasks must still be movable by their new indexes.
+	if err := service.Task.SortTaskInSameCategory(
+		board, tasks[2].ID, 1, 0, columnID(t, service, board.ID, "TODO"),
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveTask(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+
+	tasks := []*Task{
+		{BoardID: board.ID, UserID: user.ID, Content: "A"},
+		{BoardID: board.ID, UserID: user.ID, Content: "B"},
+		{BoardID: board.ID, UserID: user.ID, Content: "C"},
+	}
+	for _, task := range tasks {
+		if err := service.Task.Insert(board, task, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := service.Task.Archive(board, tasks[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Task.Archive(board, tasks[0].ID); err != ErrTaskNotFound {
+		t.Errorf("want %v; got %v", ErrTaskNotFound, err)
+	}
+
+	allTasks, err := service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"B", "C"}
+	got := []string{}
+	for _, t := range byName(allTasks)["TODO"].Tasks {
+		got = append(got, t.Content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("archive task failed, got = %v, want = %v", got, want)
+	}
+
+	archived, total, err := service.Task.GetArchived(board.ID, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(archived) != 1 || archived[0].ID != tasks[0].ID {
+		t.Errorf("get archived failed, got = %v, total = %d", archived, total)
+	}
+	if archived[0].ArchivedAt == nil {
+		t.Error("archived task should have archived_at set")
+	}
+
+	if err := service.Task.Unarchive(board, tasks[0].ID, false); err != nil {
+		t.Fatal(err)
+	}
+	allTasks, err = service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"B", "C", "A"}
+	got = []string{}
+	for _, t := range byName(allTasks)["TODO"].Tasks {
+		got = append(got, t.Content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unarchive task failed, got = %v, want = %v", got, want)
+	}
+}
+
 func TestSortTaskInSameCategory(t *testing.T) {
 	db, tear := newTestDB(t)
 	defer tear()
@@ -49,31 +236,32 @@ func TestSortTaskInSameCategory(t *testing.T) {
 	if err := service.User.Create(user); err != nil {
 		t.Fatal(err)
 	}
+	board := newTestBoard(t, service, user)
 
 	tasks := []*Task{
-		{UserID: user.ID, Content: "A"},
-		{UserID: user.ID, Content: "B"},
-		{UserID: user.ID, Content: "C"},
+		{BoardID: board.ID, UserID: user.ID, Content: "A"},
+		{BoardID: board.ID, UserID: user.ID, Content: "B"},
+		{BoardID: board.ID, UserID: user.ID, Content: "C"},
 	}
 	want := []string{"B", "C", "A"}
 	got := []string{}
 	for _, task := range tasks {
-		if err := service.Task.Insert(task); err != nil {
+		if err := service.Task.Insert(board, task, false); err != nil {
 			t.Fatal(err)
 		}
 	}
 	if err := service.Task.SortTaskInSameCategory(
-		user.ID, tasks[0].ID, 0, 2, "TODO",
+		board, tasks[0].ID, 0, 2, columnID(t, service, board.ID, "TODO"),
 	); err != nil {
 		t.Fatal(err)
 	}
-	allTasks, err := service.Task.GetAll(user.ID)
+	allTasks, err := service.Task.GetAll(board.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	val, ok := allTasks["TODO"]
+	val, ok := byName(allTasks)["TODO"]
 	if ok {
-		for _, t := range val {
+		for _, t := range val.Tasks {
 			got = append(got, t.Content)
 		}
 	}
@@ -96,43 +284,47 @@ func TestSortTaskInDifferentCategory(t *testing.T) {
 	if err := service.User.Create(user); err != nil {
 		t.Fatal(err)
 	}
+	board := newTestBoard(t, service, user)
 
 	tasks := []*Task{
-		{UserID: user.ID, Content: "A"},
-		{UserID: user.ID, Content: "B"},
-		{UserID: user.ID, Content: "C"},
-		{UserID: user.ID, Content: "D"},
+		{BoardID: board.ID, UserID: user.ID, Content: "A"},
+		{BoardID: board.ID, UserID: user.ID, Content: "B"},
+		{BoardID: board.ID, UserID: user.ID, Content: "C"},
+		{BoardID: board.ID, UserID: user.ID, Content: "D"},
 	}
 	wantInTodo := []string{"A", "C", "D"}
 	gotInTodo := []string{}
 	wantInTesting := []string{"B"}
 	gotInTesting := []string{}
 	for _, task := range tasks {
-		if err := service.Task.Insert(task); err != nil {
+		if err := service.Task.Insert(board, task, false); err != nil {
 			t.Fatal(err)
 		}
 	}
 	if err := service.Task.SortTaskInDifferentCategory(
-		user.ID, tasks[1].ID, 1, 0, "TODO", "TESTING",
+		board, tasks[1].ID, 1, 0,
+		columnID(t, service, board.ID, "TODO"),
+		columnID(t, service, board.ID, "TESTING"),
+		false,
 	); err != nil {
 		t.Fatal(err)
 	}
-	allTasks, err := service.Task.GetAll(user.ID)
+	allTasks, err := service.Task.GetAll(board.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	val, ok := allTasks["TODO"]
+	val, ok := byName(allTasks)["TODO"]
 	if ok {
-		for _, t := range val {
+		for _, t := range val.Tasks {
 			gotInTodo = append(gotInTodo, t.Content)
 		}
 	}
 	if !reflect.DeepEqual(gotInTodo, wantInTodo) {
 		t.Errorf("sort in different category failed, got = %v, want = %v", gotInTodo, wantInTodo)
 	}
-	val, ok = allTasks["TESTING"]
+	val, ok = byName(allTasks)["TESTING"]
 	if ok {
-		for _, t := range val {
+		for _, t := range val.Tasks {
 			gotInTesting = append(gotInTesting, t.Content)
 		}
 	}
@@ -140,3 +332,442 @@ func TestSortTaskInDifferentCategory(t *testing.T) {
 		t.Errorf("sort in different category failed, got = %v, want = %v", gotInTesting, wantInTesting)
 	}
 }
+
+func TestWIPLimit(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+	columns, err := service.Column.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo, inProgress := columns[0], columns[1]
+	inProgress.WIPLimit = 1
+	if err := service.Column.Update(board, &inProgress); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks := []*Task{
+		{BoardID: board.ID, ColumnID: todo.ID, UserID: user.ID, Content: "A"},
+		{BoardID: board.ID, ColumnID: todo.ID, UserID: user.ID, Content: "B"},
+		{BoardID: board.ID, ColumnID: todo.ID, UserID: user.ID, Content: "C"},
+	}
+	for _, task := range tasks {
+		if err := service.Task.Insert(board, task, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := service.Task.SortTaskInDifferentCategory(
+		board, tasks[0].ID, 0, 0, todo.ID, inProgress.ID, false,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Task.SortTaskInDifferentCategory(
+		board, tasks[1].ID, 0, 0, todo.ID, inProgress.ID, false,
+	); err != ErrWIPLimitExceeded {
+		t.Errorf("want %v; got %v", ErrWIPLimitExceeded, err)
+	}
+	full := &Task{BoardID: board.ID, ColumnID: inProgress.ID, UserID: user.ID, Content: "D"}
+	if err := service.Task.Insert(board, full, false); err != ErrWIPLimitExceeded {
+		t.Errorf("want %v; got %v", ErrWIPLimitExceeded, err)
+	}
+
+	if err := service.Task.SortTaskInDifferentCategory(
+		board, tasks[1].ID, 0, 1, todo.ID, inProgress.ID, true,
+	); err != nil {
+		t.Fatal(err)
+	}
+	allTasks, err := service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"A", "B"}
+	got := []string{}
+	for _, t := range byName(allTasks)["IN PROGRESS"].Tasks {
+		got = append(got, t.Content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("override wip limit failed, got = %v, want = %v", got, want)
+	}
+
+	if err := service.Task.Archive(board, tasks[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Task.Unarchive(board, tasks[0].ID, false); err != ErrWIPLimitExceeded {
+		t.Errorf("unarchive into a full column: want %v; got %v", ErrWIPLimitExceeded, err)
+	}
+	if err := service.Task.Unarchive(board, tasks[0].ID, true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+
+	tasks := []*Task{
+		{BoardID: board.ID, UserID: user.ID, Content: "A"},
+		{BoardID: board.ID, UserID: user.ID, Content: "B"},
+		{BoardID: board.ID, UserID: user.ID, Content: "C"},
+	}
+	for _, task := range tasks {
+		if err := service.Task.Insert(board, task, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := db.Exec(`update tasks set rank = rank || 'zzzzzzzzzzzzzzzzV' where board_id = $1`, board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := service.Task.Rebalance(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 rebalanced column, got %d", n)
+	}
+	var longest int
+	row := db.QueryRow(`select max(length(rank)) from tasks where board_id = $1`, board.ID)
+	if err := row.Scan(&longest); err != nil {
+		t.Fatal(err)
+	}
+	if longest > 12 {
+		t.Errorf("rank keys should be compacted, longest = %d", longest)
+	}
+
+	allTasks, err := service.Task.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"A", "B", "C"}
+	got := []string{}
+	for _, t := range byName(allTasks)["TODO"].Tasks {
+		got = append(got, t.Content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rebalance changed the order, got = %v, want = %v", got, want)
+	}
+}
+
+// assertOrderIntact checks that every task in want appears exactly once on
+// the board and that no two tasks in a column share a rank key.
+func assertOrderIntact(t *testing.T, service Service, boardID int64, want []*Task) {
+	t.Helper()
+	allTasks, err := service.Task.GetAll(boardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int64]int{}
+	for _, column := range allTasks {
+		for _, task := range column.Tasks {
+			seen[task.ID]++
+		}
+	}
+	if len(seen) != len(want) {
+		t.Errorf("want %d tasks on the board, got %d", len(want), len(seen))
+	}
+	for _, task := range want {
+		if seen[task.ID] != 1 {
+			t.Errorf("task %d appears %d times", task.ID, seen[task.ID])
+		}
+	}
+
+	query := `
+        select count(*) from (
+            select column_id, rank from tasks
+            where board_id = $1 and archived_at is null
+            group by column_id, rank
+            having count(*) > 1
+        ) as duplicates
+    `
+	var duplicates int
+	if err := service.Task.DB.QueryRow(query, boardID).Scan(&duplicates); err != nil {
+		t.Fatal(err)
+	}
+	if duplicates != 0 {
+		t.Errorf("found %d duplicated rank keys", duplicates)
+	}
+}
+
+func TestConcurrentSort(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+
+	tasks := []*Task{}
+	for i := 0; i < 20; i++ {
+		task := &Task{UserID: user.ID, Content: "task"}
+		if err := service.Task.Insert(board, task, false); err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, task)
+	}
+
+	const workers, moves = 8, 15
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		// Compact keys while cards are moving to exercise the column locks.
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := service.Task.Rebalance(0); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for moved := 0; moved < moves; {
+				current, err := service.Board.Get(user.ID, board.ID)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				columns, err := service.Task.GetAll(board.ID)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				source := columns[r.Intn(len(columns))]
+				if len(source.Tasks) == 0 {
+					continue
+				}
+				destination := columns[r.Intn(len(columns))]
+				sourceIndex := r.Intn(len(source.Tasks))
+				taskID := source.Tasks[sourceIndex].ID
+				if source.ID == destination.ID {
+					err = service.Task.SortTaskInSameCategory(
+						current, taskID, int64(sourceIndex), int64(r.Intn(len(source.Tasks))), source.ID,
+					)
+				} else {
+					err = service.Task.SortTaskInDifferentCategory(
+						current, taskID, int64(sourceIndex), int64(r.Intn(len(destination.Tasks)+1)),
+						source.ID, destination.ID, false,
+					)
+				}
+				switch {
+				case errors.Is(err, ErrEditConflict), errors.Is(err, ErrInvalidData):
+					continue
+				case err != nil:
+					t.Error(err)
+					return
+				}
+				moved++
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+	close(done)
+
+	assertOrderIntact(t, service, board.ID, tasks)
+}
+
+func TestConcurrentInsert(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+
+	const workers, inserts = 8, 10
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	tasks := []*Task{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for inserted := 0; inserted < inserts; {
+				current, err := service.Board.Get(user.ID, board.ID)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				task := &Task{UserID: user.ID, Content: "task"}
+				err = service.Task.Insert(current, task, false)
+				switch {
+				case errors.Is(err, ErrEditConflict):
+					continue
+				case err != nil:
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				tasks = append(tasks, task)
+				mu.Unlock()
+				inserted++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(tasks) != workers*inserts {
+		t.Fatalf("want %d inserted tasks, got %d", workers*inserts, len(tasks))
+	}
+	assertOrderIntact(t, service, board.ID, tasks)
+}
+
+// TestConcurrentSortAndRebalance moves cards between two columns from a
+// single writer, so no change is turned away by the board version, while
+// Rebalance rewrites the same columns. Only the column locks keep the two
+// from computing keys off ranks the other is replacing.
+func TestConcurrentSortAndRebalance(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+	columns, err := service.Column.GetAll(board.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo, inProgress := columns[0].ID, columns[1].ID
+
+	// want mirrors the order the moves should leave each column in.
+	want := map[int64][]int64{todo: {}, inProgress: {}}
+	for i := 0; i < 20; i++ {
+		task := &Task{ColumnID: todo, UserID: user.ID, Content: "task"}
+		if err := service.Task.Insert(board, task, false); err != nil {
+			t.Fatal(err)
+		}
+		want[todo] = append(want[todo], task.ID)
+	}
+
+	const rebalancers, moves = 4, 100
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < rebalancers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := service.Task.Rebalance(0); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	r := rand.New(rand.NewSource(1))
+	source, destination := todo, inProgress
+	for moved := 0; moved < moves; moved++ {
+		if len(want[source]) == 0 {
+			source, destination = destination, source
+		}
+		sourceIndex := r.Intn(len(want[source]))
+		destinationIndex := r.Intn(len(want[destination]) + 1)
+		taskID := want[source][sourceIndex]
+		err := service.Task.SortTaskInDifferentCategory(
+			board, taskID, int64(sourceIndex), int64(destinationIndex), source, destination, false,
+		)
+		if err != nil {
+			t.Errorf("move %d: %v", moved, err)
+			break
+		}
+		want[source] = append(want[source][:sourceIndex:sourceIndex], want[source][sourceIndex+1:]...)
+		want[destination] = append(
+			want[destination][:destinationIndex:destinationIndex],
+			append([]int64{taskID}, want[destination][destinationIndex:]...)...,
+		)
+		if r.Intn(4) == 0 {
+			source, destination = destination, source
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	for _, columnID := range []int64{todo, inProgress} {
+		rows, err := db.Query(`
+            select id, rank from tasks
+            where column_id = $1 and archived_at is null
+            order by rank
+        `, columnID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []int64{}
+		previous := ""
+		for rows.Next() {
+			var id int64
+			var rank string
+			if err := rows.Scan(&id, &rank); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) > 0 && rank <= previous {
+				t.Errorf("column %d: rank %q of task %d does not follow %q", columnID, rank, id, previous)
+			}
+			got = append(got, id)
+			previous = rank
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want[columnID]) {
+			t.Errorf("column %d: got order %v, want %v", columnID, got, want[columnID])
+		}
+	}
+}
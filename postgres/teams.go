@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrTeamNotFound       = errors.New("team not found")
+	ErrDuplicateTeamName  = errors.New("team name already exists")
+	ErrTeamMemberNotFound = errors.New("team member not found")
+)
+
+// Team is a group of organization members that can be granted a role on
+// boards of the organization all at once.
+type Team struct {
+	ID        int64     `json:"id"`
+	OrgID     int64     `json:"org_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TeamBoard is a board a team has been granted a role on.
+type TeamBoard struct {
+	BoardID int64  `json:"board_id"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+}
+
+type TeamService struct {
+	DB *sql.DB
+}
+
+func (ts TeamService) Create(team *Team) error {
+	query := `
+        insert into teams (org_id, name)
+        values ($1, $2)
+        returning id, created_at
+    `
+	row := ts.DB.QueryRowContext(context.Background(), query, team.OrgID, team.Name)
+	err := row.Scan(&team.ID, &team.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateTeamName
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts TeamService) GetAll(orgID int64) ([]Team, error) {
+	query := `
+        select id, org_id, name, created_at
+        from teams
+        where org_id = $1
+        order by name
+    `
+	rows, err := ts.DB.QueryContext(context.Background(), query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []Team{}
+	for rows.Next() {
+		team := Team{}
+		if err := rows.Scan(&team.ID, &team.OrgID, &team.Name, &team.CreatedAt); err != nil {
+			return nil, err
+		}
+		teams = append(teams, team)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (ts TeamService) Get(orgID, teamID int64) (*Team, error) {
+	query := `
+        select id, org_id, name, created_at
+        from teams
+        where id = $1 and org_id = $2
+    `
+	row := ts.DB.QueryRowContext(context.Background(), query, teamID, orgID)
+	team := Team{}
+	err := row.Scan(&team.ID, &team.OrgID, &team.Name, &team.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrTeamNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &team, nil
+}
+
+// Delete removes the team, taking away the access to boards its members had
+// through it.
+func (ts TeamService) Delete(orgID, teamID int64) error {
+	query := `delete from teams where id = $1 and org_id = $2`
+	result, err := ts.DB.ExecContext(context.Background(), query, teamID, orgID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTeamNotFound
+	}
+	return nil
+}
+
+// GetMembers returns the members of the team along with their role in the
+// organization.
+func (ts TeamService) GetMembers(teamID int64) ([]Member, error) {
+	query := `
+        select users.id, username, email, org_members.role, team_members.created_at
+        from team_members
+        join teams on teams.id = team_members.team_id
+        join org_members on org_members.org_id = teams.org_id and org_members.user_id = team_members.user_id
+        join users on users.id = team_members.user_id
+        where team_members.team_id = $1
+        order by team_members.created_at, users.id
+    `
+	rows, err := ts.DB.QueryContext(context.Background(), query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		member := Member{}
+		err := rows.Scan(&member.UserID, &member.Username, &member.Email, &member.Role, &member.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddMember adds a member of the team's organization to the team. Adding
+// someone already on the team does nothing.
+func (ts TeamService) AddMember(team *Team, userID int64) error {
+	query := `
+        insert into team_members (team_id, user_id)
+        select $1, user_id from org_members
+        where org_id = $2 and user_id = $3
+        on conflict (team_id, user_id) do nothing
+        returning user_id
+    `
+	args := []any{team.ID, team.OrgID, userID}
+	row := ts.DB.QueryRowContext(context.Background(), query, args...)
+	var id int64
+	err := row.Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			// Nothing was inserted: the user is either on the team already
+			// or not in the organization.
+			_, err := OrgService{DB: ts.DB}.GetMember(team.OrgID, userID)
+			return err
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts TeamService) RemoveMember(teamID, userID int64) error {
+	query := `delete from team_members where team_id = $1 and user_id = $2`
+	result, err := ts.DB.ExecContext(context.Background(), query, teamID, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTeamMemberNotFound
+	}
+	return nil
+}
+
+func (ts TeamService) GetBoards(teamID int64) ([]TeamBoard, error) {
+	query := `
+        select boards.id, boards.name, board_teams.role
+        from board_teams
+        join boards on boards.id = board_teams.board_id
+        where board_teams.team_id = $1
+        order by boards.id
+    `
+	rows, err := ts.DB.QueryContext(context.Background(), query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	boards := []TeamBoard{}
+	for rows.Next() {
+		board := TeamBoard{}
+		if err := rows.Scan(&board.BoardID, &board.Name, &board.Role); err != nil {
+			return nil, err
+		}
+		boards = append(boards, board)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return boards, nil
+}
+
+// GrantBoard gives every member of the team role on a board of the team's
+// organization, replacing the role the team had on it. Access follows team
+// membership, so later members get it too.
+func (ts TeamService) GrantBoard(team *Team, boardID int64, role string) (*TeamBoard, error) {
+	query := `
+        insert into board_teams (board_id, team_id, role)
+        select id, $2, $3 from boards
+        where id = $1 and org_id = $4
+        on conflict (board_id, team_id) do update
+        set role = excluded.role
+        returning board_id, role
+    `
+	args := []any{boardID, team.ID, role, team.OrgID}
+	row := ts.DB.QueryRowContext(context.Background(), query, args...)
+	board := TeamBoard{}
+	err := row.Scan(&board.BoardID, &board.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrBoardNotFound
+		default:
+			return nil, err
+		}
+	}
+	query = `select name from boards where id = $1`
+	row = ts.DB.QueryRowContext(context.Background(), query, board.BoardID)
+	if err := row.Scan(&board.Name); err != nil {
+		return nil, err
+	}
+	return &board, nil
+}
+
+func (ts TeamService) RevokeBoard(teamID, boardID int64) error {
+	query := `delete from board_teams where team_id = $1 and board_id = $2`
+	result, err := ts.DB.ExecContext(context.Background(), query, teamID, boardID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrBoardNotFound
+	}
+	return nil
+}
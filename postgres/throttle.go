@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LockoutPolicy decides how long a key is locked out after failed attempts.
+// Once Threshold failures pile up within Window of each other, the key is
+// locked for BaseDelay, doubling with every further failure up to MaxDelay.
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// delay returns how long to lock a key out after its nth failure.
+func (p LockoutPolicy) delay(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.Threshold; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// ThrottleService counts failed attempts per key, such as an email address
+// or a client IP, and locks keys out according to a LockoutPolicy.
+type ThrottleService struct {
+	DB *sql.DB
+}
+
+// Check returns how long the most locked out of keys stays locked, or zero
+// if none of them is.
+func (ts ThrottleService) Check(keys ...string) (time.Duration, error) {
+	query := `
+        select coalesce(max(extract(epoch from locked_until - now())), 0)
+        from login_failures
+        where key = any($1) and locked_until > now()
+    `
+	var seconds float64
+	row := ts.DB.QueryRowContext(context.Background(), query, pq.Array(keys))
+	if err := row.Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Fail records a failed attempt for key and locks it out if policy says so.
+func (ts ThrottleService) Fail(key string, policy LockoutPolicy) error {
+	tx, err := ts.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        insert into login_failures (key, failures, last_failure_at)
+        values ($1, 1, now())
+        on conflict (key) do update
+        set failures = case
+            when login_failures.last_failure_at < now() - make_interval(secs => $2) then 1
+            else login_failures.failures + 1
+        end,
+        last_failure_at = now()
+        returning failures
+    `
+	var failures int
+	row := tx.QueryRowContext(context.Background(), query, key, policy.Window.Seconds())
+	if err := row.Scan(&failures); err != nil {
+		return err
+	}
+	if delay := policy.delay(failures); delay > 0 {
+		queryLock := `
+            update login_failures
+            set locked_until = now() + make_interval(secs => $2)
+            where key = $1
+        `
+		if _, err := tx.ExecContext(context.Background(), queryLock, key, delay.Seconds()); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reset forgets the failed attempts of key.
+func (ts ThrottleService) Reset(key string) error {
+	query := `delete from login_failures where key = $1`
+	_, err := ts.DB.ExecContext(context.Background(), query, key)
+	return err
+}
+
+// DeleteStale deletes up to batchSize failure records that are no longer
+// locked and older than window, and returns how many it deleted. window
+// should be at least the largest LockoutPolicy.Window in use.
+func (ts ThrottleService) DeleteStale(window time.Duration, batchSize int) (int, error) {
+	query := `
+        delete from login_failures
+        where key in (
+            select key from login_failures
+            where last_failure_at < now() - make_interval(secs => $2)
+            and (locked_until is null or locked_until < now())
+            limit $1
+            for update skip locked
+        )
+    `
+	return deleteBatch(ts.DB, query, batchSize, window.Seconds())
+}
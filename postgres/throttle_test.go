@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicyDelay(t *testing.T) {
+	policy := LockoutPolicy{
+		Threshold: 3,
+		BaseDelay: time.Minute,
+		MaxDelay:  10 * time.Minute,
+	}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 6, want: 8 * time.Minute},
+		{failures: 7, want: 10 * time.Minute},
+		{failures: 100, want: 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.delay(tt.failures); got != tt.want {
+			t.Errorf("failures = %d: want %v; got %v", tt.failures, tt.want, got)
+		}
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	policy := LockoutPolicy{
+		Threshold: 2,
+		BaseDelay: time.Minute,
+		MaxDelay:  time.Hour,
+		Window:    time.Hour,
+	}
+	key := "email:kishor@gmail.com"
+	for i := 0; i < 2; i++ {
+		wait, err := service.Throttle.Check(key, "ip:10.0.0.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != 0 {
+			t.Fatalf("attempt %d: want no wait; got %v", i, wait)
+		}
+		if err := service.Throttle.Fail(key, policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wait, err := service.Throttle.Check("ip:10.0.0.1", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("want a wait of up to a minute; got %v", wait)
+	}
+
+	if err := service.Throttle.Reset(key); err != nil {
+		t.Fatal(err)
+	}
+	wait, err = service.Throttle.Check(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait != 0 {
+		t.Errorf("want no wait after reset; got %v", wait)
+	}
+}
@@ -6,7 +6,35 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrTokenNotFound   = errors.New("token not found")
+	// ErrRefreshTokenReused is returned when a refresh token is presented a
+	// second time. The session it belonged to has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Purposes a token is issued for. Only authentication tokens are accepted
+// by GetForToken and Get.
+const (
+	PurposeAuthentication = "authentication"
+	PurposePasswordReset  = "password-reset"
+	PurposeActivation     = "activation"
+	PurposeTwoFactor      = "two-factor"
+)
+
+// Scopes a personal access token can be granted. Login sessions are not
+// scoped and may do anything the user can.
+const (
+	ScopeTasksRead   = "tasks:read"
+	ScopeTasksWrite  = "tasks:write"
+	ScopeBoardsAdmin = "boards:admin"
 )
 
 type Token struct {
@@ -14,6 +42,54 @@ type Token struct {
 	Hash      []byte
 	UserID    int64
 	Expiry    time.Time
+	Purpose   string
+	// SessionID is the opaque id under which the token is listed and revoked
+	// as a session.
+	SessionID string
+	UserAgent string
+	ClientIP  string
+	// Name and Scopes are only set on personal access tokens. A nil Scopes
+	// marks a login session.
+	Name   string
+	Scopes []string
+}
+
+// HasScope reports whether the token may be used for routes requiring scope.
+func (t *Token) HasScope(scope string) bool {
+	if t.Scopes == nil {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// PersonalToken describes a personal access token without its secret.
+type PersonalToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Expiry     time.Time  `json:"expiry"`
+}
+
+// Session describes where a user is logged in.
+type Session struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	CreatedAt time.Time `json:"created_at"`
+	// LastUserAgent and LastClientIP are the client that last used the
+	// session, which may differ from the one that logged in.
+	LastUserAgent string     `json:"last_user_agent"`
+	LastClientIP  string     `json:"last_client_ip"`
+	LastUsedAt    *time.Time `json:"last_used_at"`
+	Expiry        time.Time  `json:"expiry"`
+	Current       bool       `json:"current"`
 }
 
 type TokenService struct {
@@ -22,21 +98,43 @@ type TokenService struct {
 
 func generateToken(userID int64, ttl time.Duration) (*Token, error) {
 	token := &Token{
-		UserID: userID,
-		Expiry: time.Now().Add(ttl),
+		UserID:  userID,
+		Expiry:  time.Now().Add(ttl),
+		Purpose: PurposeAuthentication,
 	}
-	b := make([]byte, 16)
-	_, err := rand.Read(b)
+	var err error
+	token.PlainText, token.Hash, err = randomSecret()
 	if err != nil {
 		return nil, err
 	}
 
-	token.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
-	h := sha256.Sum256([]byte(token.PlainText))
-	token.Hash = h[:]
+	token.SessionID, err = randomID()
+	if err != nil {
+		return nil, err
+	}
 	return token, nil
 }
 
+// randomSecret returns a random secret to hand out and the hash of it to
+// store, so a leaked table does not give the secrets away.
+func randomSecret() (string, []byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	plainText := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	hash := sha256.Sum256([]byte(plainText))
+	return plainText, hash[:], nil
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
 func (t TokenService) New(userID int64, ttl time.Duration) (*Token, error) {
 	token, err := generateToken(userID, ttl)
 	if err != nil {
@@ -49,12 +147,568 @@ func (t TokenService) New(userID int64, ttl time.Duration) (*Token, error) {
 	return token, nil
 }
 
+// NewSession logs the user in from the given client. It returns an access
+// token valid for ttl and a refresh token valid for refreshTTL that trades
+// for a new pair through Refresh. Both belong to the same session, which is
+// the family revoked when a refresh token is replayed.
+func (t TokenService) NewSession(userID int64, ttl, refreshTTL time.Duration, userAgent, clientIP string) (*Token, *Token, error) {
+	access, err := generateToken(userID, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	access.UserAgent = userAgent
+	access.ClientIP = clientIP
+	refresh, err := generateToken(userID, refreshTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh.SessionID = access.SessionID
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertToken(tx, access); err != nil {
+		return nil, nil, err
+	}
+	if err := insertRefreshToken(tx, refresh); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// Refresh trades a refresh token for a new access token and refresh token
+// of the same session. Each refresh token works once: presenting one that
+// was already used revokes the whole session and reports
+// ErrRefreshTokenReused, since either the client or a thief holds a stolen
+// copy.
+func (t TokenService) Refresh(plainText string, ttl, refreshTTL time.Duration, userAgent, clientIP string) (*Token, *Token, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	hash := sha256.Sum256([]byte(plainText))
+	query := `
+        select user_id, session_id, used_at is not null, expiry > now()
+        from refresh_tokens
+        where hash = $1
+        for update
+    `
+	var userID int64
+	var sessionID string
+	var used, valid bool
+	row := tx.QueryRowContext(context.Background(), query, hash[:])
+	if err := row.Scan(&userID, &sessionID, &used, &valid); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrTokenNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+	if used {
+		if _, err := deleteSession(tx, userID, sessionID); err != nil {
+			return nil, nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+	if !valid {
+		return nil, nil, ErrTokenNotFound
+	}
+
+	queryUse := `update refresh_tokens set used_at = now() where hash = $1`
+	if _, err := tx.ExecContext(context.Background(), queryUse, hash[:]); err != nil {
+		return nil, nil, err
+	}
+
+	access, err := generateToken(userID, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	access.SessionID = sessionID
+	access.UserAgent = userAgent
+	access.ClientIP = clientIP
+	refresh, err := generateToken(userID, refreshTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	refresh.SessionID = sessionID
+
+	// The session keeps a single access token row, so it is listed once and
+	// keeps its created_at across refreshes.
+	queryReplace := `
+        update tokens
+        set hash = $1, expiry = $2, last_user_agent = $3, last_client_ip = $4, last_used_at = now()
+        where user_id = $5 and session_id = $6 and scopes is null
+    `
+	args := []any{access.Hash, access.Expiry, userAgent, clientIP, userID, sessionID}
+	result, err := tx.ExecContext(context.Background(), queryReplace, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, nil, err
+	}
+	if rowsAffected == 0 {
+		if err := insertToken(tx, access); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := insertRefreshToken(tx, refresh); err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// NewPersonal issues a named personal access token limited to scopes.
+func (t TokenService) NewPersonal(userID int64, ttl time.Duration, name string, scopes []string) (*Token, error) {
+	token, err := generateToken(userID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	token.Name = name
+	token.Scopes = append([]string{}, scopes...)
+	err = t.Insert(token)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// NewPasswordReset issues a single-use token that lets the user set a new
+// password through UserService.ResetPassword.
+func (t TokenService) NewPasswordReset(userID int64, ttl time.Duration) (*Token, error) {
+	return t.newSingleUse(userID, ttl, PurposePasswordReset)
+}
+
+// NewActivation issues a single-use token that verifies the user's email
+// address through UserService.Activate.
+func (t TokenService) NewActivation(userID int64, ttl time.Duration) (*Token, error) {
+	return t.newSingleUse(userID, ttl, PurposeActivation)
+}
+
+// NewTwoFactorChallenge issues a single-use token proving the user got the
+// password right. UserService.LoginSecondFactor trades it, together with a
+// second factor, for the user.
+func (t TokenService) NewTwoFactorChallenge(userID int64, ttl time.Duration) (*Token, error) {
+	return t.newSingleUse(userID, ttl, PurposeTwoFactor)
+}
+
+// newSingleUse issues a token for purpose. Tokens for the same purpose
+// issued earlier to the user stop working.
+func (t TokenService) newSingleUse(userID int64, ttl time.Duration, purpose string) (*Token, error) {
+	token, err := generateToken(userID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	token.Purpose = purpose
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        delete from tokens
+        where user_id = $1 and purpose = $2
+    `
+	if _, err := tx.ExecContext(context.Background(), query, userID, purpose); err != nil {
+		return nil, err
+	}
+	if err := insertToken(tx, token); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// useToken deletes the unexpired token for purpose inside tx and returns
+// the user it was issued to.
+func useToken(tx *sql.Tx, plainText, purpose string) (int64, error) {
+	hash := sha256.Sum256([]byte(plainText))
+	query := `
+        delete from tokens
+        where hash = $1 and purpose = $2 and expiry > now()
+        returning user_id
+    `
+	var userID int64
+	row := tx.QueryRowContext(context.Background(), query, hash[:], purpose)
+	if err := row.Scan(&userID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrTokenNotFound
+		default:
+			return 0, err
+		}
+	}
+	return userID, nil
+}
+
 func (t TokenService) Insert(token *Token) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertToken(tx, token); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func insertToken(tx *sql.Tx, token *Token) error {
+	query := `
+        insert into tokens (hash, user_id, expiry, purpose, session_id, user_agent, client_ip, name, scopes)
+        values ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+    `
+	args := []any{
+		token.Hash,
+		token.UserID,
+		token.Expiry,
+		token.Purpose,
+		token.SessionID,
+		token.UserAgent,
+		token.ClientIP,
+		token.Name,
+		pq.Array(token.Scopes),
+	}
+	_, err := tx.ExecContext(context.Background(), query, args...)
+	return err
+}
+
+func insertRefreshToken(tx *sql.Tx, token *Token) error {
+	query := `
+        insert into refresh_tokens (hash, user_id, session_id, expiry)
+        values ($1, $2, $3, $4)
+    `
+	args := []any{token.Hash, token.UserID, token.SessionID, token.Expiry}
+	_, err := tx.ExecContext(context.Background(), query, args...)
+	return err
+}
+
+// deleteSession removes the session's access and refresh tokens inside tx
+// and reports whether it had an access token.
+func deleteSession(tx *sql.Tx, userID int64, sessionID string) (bool, error) {
+	queryRefresh := `
+        delete from refresh_tokens
+        where user_id = $1 and session_id = $2
+    `
+	if _, err := tx.ExecContext(context.Background(), queryRefresh, userID, sessionID); err != nil {
+		return false, err
+	}
+	query := `
+        delete from tokens
+        where user_id = $1 and session_id = $2
+        and purpose = 'authentication' and scopes is null
+    `
+	result, err := tx.ExecContext(context.Background(), query, userID, sessionID)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// Delete revokes the token with the given plain text together with the
+// refresh tokens of its session.
+func (t TokenService) Delete(plainText string) error {
+	hash := sha256.Sum256([]byte(plainText))
 	query := `
-        insert into tokens (hash, user_id, expiry)
-        values ($1, $2, $3)
+        with deleted as (
+            delete from tokens
+            where hash = $1
+            returning user_id, session_id
+        )
+        delete from refresh_tokens
+        using deleted
+        where refresh_tokens.user_id = deleted.user_id
+        and refresh_tokens.session_id = deleted.session_id
     `
-	args := []any{token.Hash, token.UserID, token.Expiry}
-	_, err := t.DB.ExecContext(context.Background(), query, args...)
+	_, err := t.DB.ExecContext(context.Background(), query, hash[:])
 	return err
 }
+
+// DeleteAllForUser revokes every login session of the user. Personal access
+// tokens are left alone; they are revoked one by one with DeletePersonal.
+func (t TokenService) DeleteAllForUser(userID int64) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`delete from refresh_tokens where user_id = $1`,
+		`delete from tokens where user_id = $1 and purpose = 'authentication' and scopes is null`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(context.Background(), query, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Get returns the unexpired token with the given plain text.
+func (t TokenService) Get(plainText string) (*Token, error) {
+	hash := sha256.Sum256([]byte(plainText))
+	query := `
+        select user_id, expiry, session_id, user_agent, client_ip, name, scopes
+        from tokens
+        where hash = $1 and purpose = 'authentication' and expiry > now()
+    `
+	token := Token{
+		PlainText: plainText,
+		Hash:      hash[:],
+	}
+	row := t.DB.QueryRowContext(context.Background(), query, hash[:])
+	err := row.Scan(
+		&token.UserID,
+		&token.Expiry,
+		&token.SessionID,
+		&token.UserAgent,
+		&token.ClientIP,
+		&token.Name,
+		pq.Array(&token.Scopes),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrTokenNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &token, nil
+}
+
+// Touch records that the token was just used by the given client, keeping
+// the client it was created for. Writes are skipped while the stored values
+// are less than a minute old.
+func (t TokenService) Touch(plainText, userAgent, clientIP string) error {
+	hash := sha256.Sum256([]byte(plainText))
+	query := `
+        update tokens
+        set last_used_at = now(), last_user_agent = $2, last_client_ip = $3
+        where hash = $1
+        and (
+            last_used_at is null
+            or last_used_at < now() - interval '1 minute'
+            or last_user_agent <> $2
+            or last_client_ip <> $3
+        )
+    `
+	_, err := t.DB.ExecContext(context.Background(), query, hash[:], userAgent, clientIP)
+	return err
+}
+
+// GetSessions lists the user's sessions that are still usable, newest first.
+// A session whose access token expired lives on while it can be refreshed.
+// The session of currentToken is flagged as current.
+func (t TokenService) GetSessions(userID int64, currentToken string) ([]Session, error) {
+	hash := sha256.Sum256([]byte(currentToken))
+	query := `
+        select t.session_id, t.user_agent, t.client_ip, t.created_at,
+        t.last_user_agent, t.last_client_ip, t.last_used_at,
+        greatest(t.expiry, r.expiry), t.hash = $2
+        from tokens t
+        left join refresh_tokens r
+        on r.user_id = t.user_id and r.session_id = t.session_id and r.used_at is null
+        where t.user_id = $1 and t.purpose = 'authentication' and t.scopes is null
+        and (t.expiry > now() or r.expiry > now())
+        order by t.created_at desc
+    `
+	rows, err := t.DB.QueryContext(context.Background(), query, userID, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		session := Session{}
+		err := rows.Scan(
+			&session.ID,
+			&session.UserAgent,
+			&session.ClientIP,
+			&session.CreatedAt,
+			&session.LastUserAgent,
+			&session.LastClientIP,
+			&session.LastUsedAt,
+			&session.Expiry,
+			&session.Current,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// DeleteSession revokes one of the user's sessions.
+func (t TokenService) DeleteSession(userID int64, sessionID string) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	found, err := deleteSession(tx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetPersonal lists the user's unexpired personal access tokens, newest
+// first.
+func (t TokenService) GetPersonal(userID int64) ([]PersonalToken, error) {
+	query := `
+        select session_id, name, scopes, created_at, last_used_at, expiry
+        from tokens
+        where user_id = $1 and scopes is not null and expiry > now()
+        order by created_at desc
+    `
+	rows, err := t.DB.QueryContext(context.Background(), query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []PersonalToken{}
+	for rows.Next() {
+		token := PersonalToken{}
+		err := rows.Scan(
+			&token.ID,
+			&token.Name,
+			pq.Array(&token.Scopes),
+			&token.CreatedAt,
+			&token.LastUsedAt,
+			&token.Expiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeletePersonal revokes one of the user's personal access tokens.
+func (t TokenService) DeletePersonal(userID int64, id string) error {
+	query := `
+        delete from tokens
+        where user_id = $1 and session_id = $2 and scopes is not null
+    `
+	result, err := t.DB.ExecContext(context.Background(), query, userID, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// DeleteExpired deletes up to batchSize expired tokens and returns how many
+// it deleted. The access token of a session that can still be refreshed is
+// kept, since the session is listed through it. Rows another caller is
+// deleting are skipped, so several instances can sweep at once.
+func (t TokenService) DeleteExpired(batchSize int) (int, error) {
+	query := `
+        delete from tokens
+        where hash in (
+            select hash from tokens
+            where expiry < now()
+            and not exists (
+                select 1 from refresh_tokens
+                where refresh_tokens.user_id = tokens.user_id
+                and refresh_tokens.session_id = tokens.session_id
+                and refresh_tokens.used_at is null
+                and refresh_tokens.expiry > now()
+            )
+            limit $1
+            for update skip locked
+        )
+    `
+	return deleteBatch(t.DB, query, batchSize)
+}
+
+// DeleteExpiredRefresh deletes up to batchSize expired refresh tokens, used
+// or not, and returns how many it deleted.
+func (t TokenService) DeleteExpiredRefresh(batchSize int) (int, error) {
+	query := `
+        delete from refresh_tokens
+        where hash in (
+            select hash from refresh_tokens
+            where expiry < now()
+            limit $1
+            for update skip locked
+        )
+    `
+	return deleteBatch(t.DB, query, batchSize)
+}
+
+// deleteBatch runs a delete query taking a batch size as $1, followed by
+// args, and returns the number of rows it deleted.
+func deleteBatch(db *sql.DB, query string, batchSize int, args ...any) (int, error) {
+	result, err := db.ExecContext(context.Background(), query, append([]any{batchSize}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenDelete(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := service.Token.New(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.Token.New(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.Token.Delete(first.PlainText); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.GetForToken(first.PlainText); err != ErrUserNotFound {
+		t.Errorf("want %v; got %v", ErrUserNotFound, err)
+	}
+	if _, err := service.User.GetForToken(second.PlainText); err != nil {
+		t.Errorf("other tokens should stay valid, got %v", err)
+	}
+}
+
+func TestTokenDeleteAllForUser(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	users := []*User{
+		{Username: "kishor", Email: "kishor@gmail.com"},
+		{Username: "bibek", Email: "bibek@gmail.com"},
+	}
+	tokens := []*Token{}
+	for _, user := range users {
+		user.Password.Set("password123")
+		if err := service.User.Create(user); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			token, err := service.Token.New(user.ID, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens = append(tokens, token)
+		}
+	}
+
+	if err := service.Token.DeleteAllForUser(users[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens[:2] {
+		if _, err := service.User.GetForToken(token.PlainText); err != ErrUserNotFound {
+			t.Errorf("want %v; got %v", ErrUserNotFound, err)
+		}
+	}
+	for _, token := range tokens[2:] {
+		if _, err := service.User.GetForToken(token.PlainText); err != nil {
+			t.Errorf("tokens of other users should stay valid, got %v", err)
+		}
+	}
+}
+
+func TestTokenSessions(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	laptop, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	phone, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Android", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Token.Touch(phone.PlainText, "Android", "10.0.0.3"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := service.Token.GetSessions(user.ID, laptop.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("want 2 sessions; got %d", len(sessions))
+	}
+	for _, session := range sessions {
+		switch session.ID {
+		case laptop.SessionID:
+			if !session.Current || session.UserAgent != "Firefox" {
+				t.Errorf("unexpected laptop session %+v", session)
+			}
+		case phone.SessionID:
+			if session.Current || session.ClientIP != "10.0.0.2" || session.LastClientIP != "10.0.0.3" || session.LastUsedAt == nil {
+				t.Errorf("unexpected phone session %+v", session)
+			}
+		default:
+			t.Errorf("unexpected session %q", session.ID)
+		}
+	}
+
+	if err := service.Token.DeleteSession(user.ID, phone.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.GetForToken(phone.PlainText); err != ErrUserNotFound {
+		t.Errorf("want %v; got %v", ErrUserNotFound, err)
+	}
+	if _, err := service.User.GetForToken(laptop.PlainText); err != nil {
+		t.Errorf("other sessions should stay valid, got %v", err)
+	}
+	if err := service.Token.DeleteSession(user.ID, phone.SessionID); err != ErrSessionNotFound {
+		t.Errorf("want %v; got %v", ErrSessionNotFound, err)
+	}
+}
+
+func TestTokenPersonal(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	session, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci, err := service.Token.NewPersonal(user.ID, time.Hour, "ci", []string{ScopeTasksRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := service.Token.Get(ci.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.HasScope(ScopeTasksRead) || token.HasScope(ScopeTasksWrite) || token.HasScope("") {
+		t.Errorf("unexpected scopes %v", token.Scopes)
+	}
+	token, err = service.Token.Get(session.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !token.HasScope(ScopeBoardsAdmin) || !token.HasScope("") {
+		t.Errorf("sessions should have every scope, got %v", token.Scopes)
+	}
+
+	tokens, err := service.Token.GetPersonal(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || tokens[0].ID != ci.SessionID || tokens[0].Name != "ci" {
+		t.Fatalf("unexpected personal tokens %+v", tokens)
+	}
+	sessions, err := service.Token.GetSessions(user.ID, session.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("personal tokens should not be listed as sessions, got %+v", sessions)
+	}
+
+	if err := service.Token.DeleteAllForUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Token.Get(ci.PlainText); err != nil {
+		t.Errorf("logging out should keep personal tokens, got %v", err)
+	}
+	if err := service.Token.DeleteSession(user.ID, ci.SessionID); err != ErrSessionNotFound {
+		t.Errorf("want %v; got %v", ErrSessionNotFound, err)
+	}
+	if err := service.Token.DeletePersonal(user.ID, ci.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Token.Get(ci.PlainText); err != ErrTokenNotFound {
+		t.Errorf("want %v; got %v", ErrTokenNotFound, err)
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	access, refresh, err := service.Token.NewSession(user.ID, time.Minute, time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newAccess, newRefresh, err := service.Token.Refresh(refresh.PlainText, time.Minute, time.Hour, "Firefox", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newAccess.SessionID != access.SessionID || newRefresh.SessionID != access.SessionID {
+		t.Errorf("refreshed tokens should stay in session %q", access.SessionID)
+	}
+	if _, err := service.User.GetForToken(access.PlainText); err != ErrUserNotFound {
+		t.Errorf("old access token: want %v; got %v", ErrUserNotFound, err)
+	}
+	if _, err := service.User.GetForToken(newAccess.PlainText); err != nil {
+		t.Errorf("new access token should be valid, got %v", err)
+	}
+	sessions, err := service.Token.GetSessions(user.ID, newAccess.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].Current || sessions[0].ClientIP != "10.0.0.1" || sessions[0].LastClientIP != "10.0.0.2" {
+		t.Errorf("unexpected sessions %+v", sessions)
+	}
+
+	// Replaying the used refresh token revokes the session.
+	if _, _, err := service.Token.Refresh(refresh.PlainText, time.Minute, time.Hour, "curl", "10.6.6.6"); err != ErrRefreshTokenReused {
+		t.Fatalf("want %v; got %v", ErrRefreshTokenReused, err)
+	}
+	if _, err := service.User.GetForToken(newAccess.PlainText); err != ErrUserNotFound {
+		t.Errorf("access token of a revoked session: want %v; got %v", ErrUserNotFound, err)
+	}
+	if _, _, err := service.Token.Refresh(newRefresh.PlainText, time.Minute, time.Hour, "Firefox", "10.0.0.2"); err != ErrTokenNotFound {
+		t.Errorf("refresh token of a revoked session: want %v; got %v", ErrTokenNotFound, err)
+	}
+}
+
+func TestTokenRefreshAfterLogout(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	access, refresh, err := service.Token.NewSession(user.ID, time.Minute, time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Token.Delete(access.PlainText); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.Token.Refresh(refresh.PlainText, time.Minute, time.Hour, "Firefox", "10.0.0.1"); err != ErrTokenNotFound {
+		t.Errorf("want %v; got %v", ErrTokenNotFound, err)
+	}
+}
+
+func TestTokenDeleteExpired(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := service.Token.New(user.ID, -time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	valid, err := service.Token.New(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An expired access token that can still be refreshed is kept.
+	refreshable, refresh, err := service.Token.NewSession(user.ID, -time.Hour, time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := service.Token.NewSession(user.ID, -time.Hour, -time.Hour, "Android", "10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := service.Token.DeleteExpired(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want a batch of 2; got %d", n)
+	}
+	n, err = service.Token.DeleteExpired(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("want the 2 remaining expired tokens; got %d", n)
+	}
+	n, err = service.Token.DeleteExpiredRefresh(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("want 1 expired refresh token; got %d", n)
+	}
+
+	if _, err := service.User.GetForToken(valid.PlainText); err != nil {
+		t.Errorf("unexpired tokens should be kept, got %v", err)
+	}
+	access, _, err := service.Token.Refresh(refresh.PlainText, time.Hour, time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if access.SessionID != refreshable.SessionID {
+		t.Errorf("want session %q; got %q", refreshable.SessionID, access.SessionID)
+	}
+}
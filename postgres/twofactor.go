@@ -0,0 +1,262 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/KishorPokharel/kanban/totp"
+)
+
+// totpSkew is how many 30 second steps a code may be off by to allow for
+// clock drift on the user's device.
+const totpSkew = 1
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTOTPEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotSetUp    = errors.New("two-factor authentication is not set up")
+	ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+)
+
+// SetUpTOTP stores a new secret for the user that starts protecting logins
+// once ConfirmTOTP sees a code generated from it.
+func (us UserService) SetUpTOTP(userID int64) (string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", err
+	}
+	query := `
+        update users
+        set totp_secret = $1
+        where id = $2 and not totp_enabled
+    `
+	result, err := us.DB.ExecContext(context.Background(), query, secret, userID)
+	if err != nil {
+		return "", err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if rowsAffected == 0 {
+		return "", ErrTOTPEnabled
+	}
+	return secret, nil
+}
+
+// ConfirmTOTP enables two-factor authentication when code matches the
+// secret from SetUpTOTP. It returns the user's recovery codes, which are
+// only stored hashed and cannot be shown again.
+func (us UserService) ConfirmTOTP(userID int64, code string) ([]string, error) {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	secret, enabled, lastStep, err := lockTOTP(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case enabled:
+		return nil, ErrTOTPEnabled
+	case secret == "":
+		return nil, ErrTOTPNotSetUp
+	}
+	step, ok := totp.Verify(secret, code, time.Now(), totpSkew)
+	if !ok || step <= lastStep {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	query := `
+        update users
+        set totp_enabled = true, totp_last_step = $1
+        where id = $2
+    `
+	if _, err := tx.ExecContext(context.Background(), query, step, userID); err != nil {
+		return nil, err
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off and drops the secret and
+// recovery codes.
+func (us UserService) DisableTOTP(userID int64) error {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`update users set totp_secret = null, totp_enabled = false, totp_last_step = 0 where id = $1`,
+		`delete from recovery_codes where user_id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(context.Background(), query, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// LoginSecondFactor completes a login that NewTwoFactorChallenge started.
+// Exactly one of code, a TOTP code, and recoveryCode should be set. The
+// challenge token is used up whether or not the code is right, so a wrong
+// code sends the user back to the password step.
+func (us UserService) LoginSecondFactor(challenge, code, recoveryCode string) (*User, error) {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userID, err := useToken(tx, challenge, PurposeTwoFactor)
+	if err != nil {
+		return nil, err
+	}
+	secret, enabled, lastStep, err := lockTOTP(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrTOTPNotSetUp
+	}
+
+	verified := false
+	if recoveryCode != "" {
+		verified, err = useRecoveryCode(tx, userID, recoveryCode)
+		if err != nil {
+			return nil, err
+		}
+	} else if step, ok := totp.Verify(secret, code, time.Now(), totpSkew); ok && step > lastStep {
+		query := `update users set totp_last_step = $1 where id = $2`
+		if _, err := tx.ExecContext(context.Background(), query, step, userID); err != nil {
+			return nil, err
+		}
+		verified = true
+	}
+	if !verified {
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidTOTPCode
+	}
+
+	query := `
+        select id, username, email, activated, totp_enabled, created_at
+        from users
+        where id = $1
+    `
+	user := User{}
+	row := tx.QueryRowContext(context.Background(), query, userID)
+	err = row.Scan(&user.ID, &user.Username, &user.Email, &user.Activated, &user.TOTPEnabled, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// lockTOTP locks the user row until tx ends, so two logins cannot both
+// accept the same code, and returns the user's TOTP state.
+func lockTOTP(tx *sql.Tx, userID int64) (string, bool, int64, error) {
+	query := `
+        select coalesce(totp_secret, ''), totp_enabled, totp_last_step
+        from users
+        where id = $1
+        for update
+    `
+	var secret string
+	var enabled bool
+	var lastStep int64
+	row := tx.QueryRowContext(context.Background(), query, userID)
+	if err := row.Scan(&secret, &enabled, &lastStep); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", false, 0, ErrUserNotFound
+		default:
+			return "", false, 0, err
+		}
+	}
+	return secret, enabled, lastStep, nil
+}
+
+// replaceRecoveryCodes generates a fresh set of recovery codes for the user
+// and stores their hashes in place of any earlier ones.
+func replaceRecoveryCodes(tx *sql.Tx, userID int64) ([]string, error) {
+	queryDelete := `delete from recovery_codes where user_id = $1`
+	if _, err := tx.ExecContext(context.Background(), queryDelete, userID); err != nil {
+		return nil, err
+	}
+
+	queryInsert := `
+        insert into recovery_codes (user_id, hash)
+        values ($1, $2)
+    `
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:8] + "-" + code[8:]
+		hash := hashRecoveryCode(codes[i])
+		if _, err := tx.ExecContext(context.Background(), queryInsert, userID, hash[:]); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// useRecoveryCode marks the matching unused recovery code as used and
+// reports whether there was one.
+func useRecoveryCode(tx *sql.Tx, userID int64, code string) (bool, error) {
+	hash := hashRecoveryCode(code)
+	query := `
+        update recovery_codes
+        set used_at = now()
+        where user_id = $1 and hash = $2 and used_at is null
+    `
+	result, err := tx.ExecContext(context.Background(), query, userID, hash[:])
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// hashRecoveryCode hashes code ignoring case, spaces and dashes, so it can
+// be typed back the way it reads.
+func hashRecoveryCode(code string) [32]byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return sha256.Sum256([]byte(code))
+}
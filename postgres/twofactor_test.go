@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/KishorPokharel/kanban/totp"
+)
+
+func TestTwoFactorLogin(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := service.User.SetUpTOTP(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.ConfirmTOTP(user.ID, "000000"); err != ErrInvalidTOTPCode {
+		t.Errorf("want %v; got %v", ErrInvalidTOTPCode, err)
+	}
+	code, err := totp.Code(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := service.User.ConfirmTOTP(user.ID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("want %d recovery codes; got %d", recoveryCodeCount, len(recoveryCodes))
+	}
+	if _, err := service.User.SetUpTOTP(user.ID); err != ErrTOTPEnabled {
+		t.Errorf("want %v; got %v", ErrTOTPEnabled, err)
+	}
+
+	// The code used to confirm cannot be used again to log in.
+	challenge, err := service.Token.NewTwoFactorChallenge(user.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenged, err := service.User.GetForChallenge(challenge.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenged.ID != user.ID {
+		t.Errorf("want user %d for the challenge; got %d", user.ID, challenged.ID)
+	}
+	if _, err := service.User.GetForToken(challenge.PlainText); err != ErrUserNotFound {
+		t.Errorf("challenge is not an access token: want %v; got %v", ErrUserNotFound, err)
+	}
+	if _, err := service.User.LoginSecondFactor(challenge.PlainText, code, ""); err != ErrInvalidTOTPCode {
+		t.Errorf("want %v; got %v", ErrInvalidTOTPCode, err)
+	}
+	if _, err := service.User.LoginSecondFactor(challenge.PlainText, code, ""); err != ErrTokenNotFound {
+		t.Errorf("challenge should be used up, got %v", err)
+	}
+
+	challenge, err = service.Token.NewTwoFactorChallenge(user.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := service.User.LoginSecondFactor(challenge.PlainText, "", recoveryCodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID || !got.TOTPEnabled {
+		t.Errorf("unexpected user %+v", got)
+	}
+
+	challenge, err = service.Token.NewTwoFactorChallenge(user.ID, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.LoginSecondFactor(challenge.PlainText, "", recoveryCodes[0]); err != ErrInvalidTOTPCode {
+		t.Errorf("recovery codes work once, got %v", err)
+	}
+}
@@ -5,13 +5,17 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
-const round = 12
+const (
+	round            = 12
+	defaultBoardName = "My Board"
+)
 
 var (
 	ErrDuplicateEmail = errors.New("email already exists")
@@ -23,11 +27,16 @@ type UserService struct {
 }
 
 type User struct {
-	ID        int64
-	Username  string
-	Email     string
-	Password  password
-	CreatedAt time.Time
+	ID       int64
+	Username string
+	Email    string
+	Password password
+	// Activated is set once the user has verified their email address.
+	Activated bool
+	// TOTPEnabled is set when logins also need a code from an authenticator
+	// app.
+	TOTPEnabled bool
+	CreatedAt   time.Time
 }
 
 type password struct {
@@ -45,6 +54,23 @@ func (p *password) Set(pwd string) error {
 	return nil
 }
 
+// dummyHash is compared against when there is no user to check a password
+// for. It is computed on first use so startup does not pay for it.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a real password"), round)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// SimulatePasswordCheck takes as long as checking a password against a real
+// user. Login calls it for unknown emails so response times do not reveal
+// which addresses are registered.
+func SimulatePasswordCheck(pwd string) {
+	bcrypt.CompareHashAndPassword(dummyHash(), []byte(pwd))
+}
+
 func (p *password) Matches(pwd string) (bool, error) {
 	err := bcrypt.CompareHashAndPassword(p.hash, []byte(pwd))
 	if err != nil {
@@ -59,21 +85,64 @@ func (p *password) Matches(pwd string) (bool, error) {
 }
 
 func (us UserService) Create(user *User) error {
-	queryInsertUser := `
-        insert into users (username, email, password)
-        values ($1, $2, $3)
-        returning id, created_at
-    `
-	argsInsertUser := []any{user.Username, user.Email, user.Password.hash}
-
 	tx, err := us.DB.Begin()
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if err := insertUser(tx, user); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Register creates the user like Create, together with the activation token
+// that verifies their email address, so no account is left without one.
+func (us UserService) Register(user *User, activationTTL time.Duration) (*Token, error) {
+	token, err := generateToken(0, activationTTL)
+	if err != nil {
+		return nil, err
+	}
+	token.Purpose = PurposeActivation
+
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := insertUser(tx, user); err != nil {
+		return nil, err
+	}
+	token.UserID = user.ID
+	if err := insertToken(tx, token); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// insertUser adds the user along with their default board. The user starts
+// out activated only if user.Activated is already set.
+func insertUser(tx *sql.Tx, user *User) error {
+	queryInsertUser := `
+        insert into users (username, email, password, activated)
+        values ($1, $2, $3, $4)
+        returning id, created_at
+    `
+	argsInsertUser := []any{user.Username, user.Email, user.Password.hash, user.Activated}
+
 	userRow := tx.QueryRowContext(context.Background(), queryInsertUser, argsInsertUser...)
-	err = userRow.Scan(&user.ID, &user.CreatedAt)
+	err := userRow.Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
 		switch e := err.(type) {
 		case *pq.Error:
@@ -86,37 +155,31 @@ func (us UserService) Create(user *User) error {
 		return err
 	}
 
-	queryInsertList := `
-        insert into taskorder
-        (user_id, category, value)
-        values
-        ($1, 'TODO', array[]::bigint[]),
-        ($1, 'IN PROGRESS', array[]::bigint[]),
-        ($1, 'TESTING', array[]::bigint[]),
-        ($1, 'DONE', array[]::bigint[])
-    `
-	_, err = tx.ExecContext(context.Background(), queryInsertList, user.ID)
-	if err != nil {
-		return err
+	board := &Board{
+		UserID: user.ID,
+		Name:   defaultBoardName,
 	}
-
-	if err := tx.Commit(); err != nil {
-		return err
-	}
-
-	return nil
+	return insertBoard(tx, board)
 }
 
 func (us UserService) GetByEmail(email string) (*User, error) {
 	query := `
-		select id, username, email, password, created_at
+		select id, username, email, password, activated, totp_enabled, created_at
 		from users
 		where email = $1
 	`
 	args := []any{email}
 	row := us.DB.QueryRowContext(context.Background(), query, args...)
 	user := User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt)
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.TOTPEnabled,
+		&user.CreatedAt,
+	)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -129,19 +192,30 @@ func (us UserService) GetByEmail(email string) (*User, error) {
 }
 
 func (u UserService) GetForToken(token string) (*User, error) {
+	return u.getForToken(token, PurposeAuthentication)
+}
+
+// GetForChallenge returns the user a two-factor challenge token was issued
+// to, without using the token up.
+func (u UserService) GetForChallenge(challenge string) (*User, error) {
+	return u.getForToken(challenge, PurposeTwoFactor)
+}
+
+func (u UserService) getForToken(token, purpose string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(token))
 	query := `
-        select users.id, users.username, users.email, users.created_at
+        select users.id, users.username, users.email, users.activated, users.totp_enabled, users.created_at
         from users
         inner join tokens
         on tokens.user_id = users.id
         where tokens.hash = $1
-        and expiry > $2
+        and tokens.purpose = $2
+        and expiry > $3
     `
-	args := []any{tokenHash[:], time.Now()}
+	args := []any{tokenHash[:], purpose, time.Now()}
 	row := u.DB.QueryRowContext(context.Background(), query, args...)
 	user := User{}
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Activated, &user.TOTPEnabled, &user.CreatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -152,3 +226,240 @@ func (u UserService) GetForToken(token string) (*User, error) {
 	}
 	return &user, nil
 }
+
+// ResetPassword sets user's new password, which must already be set with
+// user.Password.Set, for the owner of the password reset token. The token is
+// used up and every login session of the user is revoked. On success user
+// is filled in with the account that was changed.
+func (us UserService) ResetPassword(token string, user *User) error {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user.ID, err = useToken(tx, token, PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	queryUpdate := `
+        update users
+        set password = $1
+        where id = $2
+        returning username, email, activated, created_at
+    `
+	row := tx.QueryRowContext(context.Background(), queryUpdate, user.Password.hash, user.ID)
+	if err := row.Scan(&user.Username, &user.Email, &user.Activated, &user.CreatedAt); err != nil {
+		return err
+	}
+
+	queries := []string{
+		`delete from refresh_tokens where user_id = $1`,
+		`delete from tokens where user_id = $1 and scopes is null`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(context.Background(), query, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Activate marks the owner of the activation token as having verified their
+// email address and uses the token up.
+func (us UserService) Activate(token string) (*User, error) {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userID, err := useToken(tx, token, PurposeActivation)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+        update users
+        set activated = true
+        where id = $1
+        returning id, username, email, activated, created_at
+    `
+	user := User{}
+	row := tx.QueryRowContext(context.Background(), query, userID)
+	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Activated, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// revokeOtherSessions signs the user out of every login session except the
+// one currentToken belongs to, and drops outstanding password reset tokens.
+// It runs inside tx after a change to the user's credentials.
+func revokeOtherSessions(tx *sql.Tx, userID int64, currentToken string) error {
+	hash := sha256.Sum256([]byte(currentToken))
+	querySession := `select session_id from tokens where hash = $1`
+	var sessionID string
+	row := tx.QueryRowContext(context.Background(), querySession, hash[:])
+	if err := row.Scan(&sessionID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	queries := []string{
+		`delete from refresh_tokens where user_id = $1 and session_id <> $2`,
+		`delete from tokens where user_id = $1 and purpose = 'authentication' and scopes is null and session_id <> $2`,
+		`delete from tokens where user_id = $1 and purpose = 'password-reset' and session_id <> $2`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(context.Background(), query, userID, sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdatePassword stores the password set on user with user.Password.Set
+// and signs the user out everywhere but the session of currentToken.
+func (us UserService) UpdatePassword(user *User, currentToken string) error {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        update users
+        set password = $1
+        where id = $2
+    `
+	result, err := tx.ExecContext(context.Background(), query, user.Password.hash, user.ID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+	if err := revokeOtherSessions(tx, user.ID, currentToken); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateEmail changes the user's email to user.Email. The new address has
+// to be verified again, so the user is no longer activated. The user is
+// signed out everywhere but the session of currentToken.
+func (us UserService) UpdateEmail(user *User, currentToken string) error {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        update users
+        set email = $1, activated = false
+        where id = $2
+        returning activated
+    `
+	row := tx.QueryRowContext(context.Background(), query, user.Email, user.ID)
+	if err := row.Scan(&user.Activated); err != nil {
+		var pqErr *pq.Error
+		switch {
+		case errors.As(err, &pqErr) && pqErr.Code == "23505":
+			return ErrDuplicateEmail
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrUserNotFound
+		default:
+			return err
+		}
+	}
+	if err := revokeOtherSessions(tx, user.ID, currentToken); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete removes the user together with the boards they created outside
+// of an organization. Their tokens and memberships go with them through the
+// schema's cascading foreign keys; boards of an organization stay. It
+// returns ErrLastOrgOwner if that would leave an organization without owners.
+func (us UserService) Delete(userID int64) error {
+	tx, err := us.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queryOwner := `
+        select exists (
+            select 1 from org_members
+            where user_id = $1 and role = 'owner'
+            and not exists (
+                select 1 from org_members others
+                where others.org_id = org_members.org_id
+                and others.role = 'owner' and others.user_id <> $1
+            )
+        )
+    `
+	var lastOwner bool
+	if err := tx.QueryRowContext(context.Background(), queryOwner, userID).Scan(&lastOwner); err != nil {
+		return err
+	}
+	if lastOwner {
+		return ErrLastOrgOwner
+	}
+	// Personal boards go with the user unless someone else also owns them;
+	// those stay, and boards.user_id is set to null.
+	queryBoards := `
+        delete from boards
+        where org_id is null
+        and exists (
+            select 1 from board_members
+            where board_id = boards.id and user_id = $1 and role = 'owner'
+        )
+        and not exists (
+            select 1 from board_members
+            where board_id = boards.id and user_id <> $1 and role = 'owner'
+        )
+    `
+	if _, err := tx.ExecContext(context.Background(), queryBoards, userID); err != nil {
+		return err
+	}
+	query := `delete from users where id = $1`
+	result, err := tx.ExecContext(context.Background(), query, userID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
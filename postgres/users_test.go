@@ -1,6 +1,9 @@
 package postgres
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestUserCreate(t *testing.T) {
 	tests := []struct {
@@ -123,3 +126,288 @@ func TestUserGetByEmail(t *testing.T) {
 		}
 	}
 }
+
+func TestUserResetPassword(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	session, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := service.Token.NewPasswordReset(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.GetForToken(reset.PlainText); err != ErrUserNotFound {
+		t.Errorf("reset tokens should not authenticate, got %v", err)
+	}
+
+	changed := &User{}
+	changed.Password.Set("newpassword123")
+	if err := service.User.ResetPassword(reset.PlainText, changed); err != nil {
+		t.Fatal(err)
+	}
+	if changed.ID != user.ID {
+		t.Errorf("want user %d; got %d", user.ID, changed.ID)
+	}
+	got, err := service.User.GetByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := got.Password.Matches("newpassword123"); !ok {
+		t.Error("new password should match")
+	}
+	if _, err := service.User.GetForToken(session.PlainText); err != ErrUserNotFound {
+		t.Errorf("sessions should be revoked, got %v", err)
+	}
+
+	again := &User{}
+	again.Password.Set("another123")
+	if err := service.User.ResetPassword(reset.PlainText, again); err != ErrTokenNotFound {
+		t.Errorf("want %v; got %v", ErrTokenNotFound, err)
+	}
+}
+
+func TestUserRegister(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	token, err := service.User.Register(user, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activated, err := service.User.Activate(token.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activated.ID != user.ID || !activated.Activated {
+		t.Errorf("unexpected user %+v", activated)
+	}
+
+	duplicate := &User{
+		Username: "kishor2",
+		Email:    "kishor@gmail.com",
+	}
+	duplicate.Password.Set("kishor123")
+	if _, err := service.User.Register(duplicate, time.Hour); err != ErrDuplicateEmail {
+		t.Errorf("want %v; got %v", ErrDuplicateEmail, err)
+	}
+}
+
+func TestUserActivate(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	if user.Activated {
+		t.Fatal("new users should not be activated")
+	}
+	token, err := service.Token.NewActivation(user.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activated, err := service.User.Activate(token.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activated.ID != user.ID || !activated.Activated {
+		t.Errorf("unexpected user %+v", activated)
+	}
+	got, err := service.User.GetByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Activated {
+		t.Error("user should be activated")
+	}
+	if _, err := service.User.Activate(token.PlainText); err != ErrTokenNotFound {
+		t.Errorf("want %v; got %v", ErrTokenNotFound, err)
+	}
+}
+
+func TestUserUpdatePassword(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	current, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Android", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user.Password.Set("newpassword123")
+	if err := service.User.UpdatePassword(user, current.PlainText); err != nil {
+		t.Fatal(err)
+	}
+	got, err := service.User.GetByEmail(user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := got.Password.Matches("newpassword123"); !ok {
+		t.Error("new password should match")
+	}
+	if _, err := service.User.GetForToken(current.PlainText); err != nil {
+		t.Errorf("current session should stay valid, got %v", err)
+	}
+	if _, err := service.User.GetForToken(other.PlainText); err != ErrUserNotFound {
+		t.Errorf("other sessions: want %v; got %v", ErrUserNotFound, err)
+	}
+}
+
+func TestUserUpdateEmail(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	users := []*User{
+		{Username: "kishor", Email: "kishor@gmail.com"},
+		{Username: "bibek", Email: "bibek@gmail.com"},
+	}
+	for _, user := range users {
+		user.Password.Set("password123")
+		if err := service.User.Create(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, err := service.Token.NewActivation(users[0].ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.Activate(token.PlainText); err != nil {
+		t.Fatal(err)
+	}
+	current, _, err := service.Token.NewSession(users[0].ID, time.Hour, 24*time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users[0].Email = "bibek@gmail.com"
+	if err := service.User.UpdateEmail(users[0], current.PlainText); err != ErrDuplicateEmail {
+		t.Errorf("want %v; got %v", ErrDuplicateEmail, err)
+	}
+
+	users[0].Email = "kishor@example.com"
+	if err := service.User.UpdateEmail(users[0], current.PlainText); err != nil {
+		t.Fatal(err)
+	}
+	got, err := service.User.GetForToken(current.PlainText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Email != "kishor@example.com" || got.Activated {
+		t.Errorf("unexpected user %+v", got)
+	}
+}
+
+func TestUserDelete(t *testing.T) {
+	db, tear := newTestDB(t)
+	defer tear()
+	service := NewService(db)
+
+	user := &User{
+		Username: "kishor",
+		Email:    "kishor@gmail.com",
+	}
+	user.Password.Set("kishor123")
+	if err := service.User.Create(user); err != nil {
+		t.Fatal(err)
+	}
+	board := newTestBoard(t, service, user)
+	task := &Task{
+		BoardID:  board.ID,
+		ColumnID: columnID(t, service, board.ID, "TODO"),
+		UserID:   user.ID,
+		Content:  "export me",
+	}
+	if err := service.Task.Insert(board, task, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.Task.Archive(board, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := service.Token.NewSession(user.ID, time.Hour, 24*time.Hour, "Firefox", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &User{
+		Username: "bibek",
+		Email:    "bibek@gmail.com",
+	}
+	other.Password.Set("bibek123")
+	if err := service.User.Create(other); err != nil {
+		t.Fatal(err)
+	}
+	shared := newTestBoard(t, service, user)
+	if _, err := service.Member.Add(shared.ID, other.Email, RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := []ExportedTask{}
+	err = service.Task.Export(user.ID, func(task ExportedTask) error {
+		exported = append(exported, task)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 1 || exported[0].ID != task.ID || exported[0].Position != nil {
+		t.Errorf("unexpected export %+v", exported)
+	}
+
+	if err := service.User.Delete(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.User.GetForToken(token.PlainText); err != ErrUserNotFound {
+		t.Errorf("want %v; got %v", ErrUserNotFound, err)
+	}
+	if _, err := service.Board.Get(user.ID, board.ID); err != ErrBoardNotFound {
+		t.Errorf("want %v; got %v", ErrBoardNotFound, err)
+	}
+	got, err := service.Board.Get(other.ID, shared.ID)
+	if err != nil {
+		t.Fatalf("board with another owner should be kept: %v", err)
+	}
+	if got.UserID != 0 {
+		t.Errorf("want creator cleared; got user %d", got.UserID)
+	}
+	if err := service.User.Delete(user.ID); err != ErrUserNotFound {
+		t.Errorf("want %v; got %v", ErrUserNotFound, err)
+	}
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
+
+	"github.com/KishorPokharel/kanban/postgres"
 )
 
 func (app *application) errorResponse(w http.ResponseWriter, status int, message string, err error) {
@@ -36,3 +39,34 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, out any)
 	}
 	w.Write(b.Bytes())
 }
+
+func (app *application) setBoardETag(w http.ResponseWriter, board *postgres.Board) {
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, board.Version))
+}
+
+// editConflictResponse answers a change made against a stale board version
+// with the current board state, so the client can rebase and retry.
+func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Println(err)
+	user := app.contextGetUser(r)
+	board, err := app.service.Board.Get(user.ID, app.contextGetBoard(r).ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	tasks, err := app.service.Task.GetAll(board.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": false,
+		"message": "Board was changed by someone else",
+		"data": map[string]any{
+			"board": board,
+			"tasks": tasks,
+		},
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusPreconditionFailed, out)
+}
@@ -0,0 +1,11 @@
+-- Migrations bring a database set up from an older sql/setup.sql up to the
+-- current one. Run the files in this directory in order, starting after the
+-- last one the database already has; a database set up from the current
+-- setup.sql needs none of them.
+--
+-- Lets tasks be archived instead of deleted.
+begin;
+
+alter table tasks add column archived_at timestamp(0) with time zone;
+
+commit;
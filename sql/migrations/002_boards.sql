@@ -0,0 +1,42 @@
+-- Moves every user's tasks and orderings onto a default board of their own.
+begin;
+
+create table boards (
+    id bigserial primary key,
+    user_id bigint not null references users(id),
+    name text not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+insert into boards (user_id, name)
+select id, 'My Board' from users order by id;
+
+alter table tasks add column board_id bigint references boards(id);
+
+update tasks
+set board_id = boards.id
+from boards
+where boards.user_id = tasks.user_id;
+
+alter table tasks alter column board_id set not null;
+
+alter table taskorder add column board_id bigint references boards(id);
+
+update taskorder
+set board_id = boards.id
+from boards
+where boards.user_id = taskorder.user_id;
+
+alter table taskorder drop column user_id;
+
+alter table taskorder alter column board_id set not null;
+
+alter table taskorder add primary key (board_id, category);
+
+-- Every board starts with an ordering for each category, as new boards do.
+insert into taskorder (board_id, category, value)
+select boards.id, category, array[]::bigint[]
+from boards, unnest(enum_range(null::categorytype)) as category
+on conflict (board_id, category) do nothing;
+
+commit;
@@ -0,0 +1,55 @@
+-- Replaces the fixed categorytype enum with columns of each board. Every
+-- board gets the four default columns, named after the old categories, and
+-- tasks and orderings move to the column of their category.
+begin;
+
+create table board_columns (
+    id bigserial primary key,
+    board_id bigint not null references boards(id),
+    name text not null,
+    color text not null,
+    position integer not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+insert into board_columns (board_id, name, color, position)
+select boards.id, defaults.name, defaults.color, defaults.position
+from boards, (values
+    ('TODO', '#9e9e9e', 0),
+    ('IN PROGRESS', '#2196f3', 1),
+    ('TESTING', '#ff9800', 2),
+    ('DONE', '#4caf50', 3)
+) as defaults (name, color, position)
+order by boards.id, defaults.position;
+
+alter table tasks add column column_id bigint references board_columns(id);
+
+update tasks
+set column_id = board_columns.id
+from board_columns
+where board_columns.board_id = tasks.board_id
+and board_columns.name = tasks.category::text;
+
+alter table tasks alter column column_id set not null;
+
+alter table tasks drop column category;
+
+alter table taskorder add column column_id bigint references board_columns(id);
+
+update taskorder
+set column_id = board_columns.id
+from board_columns
+where board_columns.board_id = taskorder.board_id
+and board_columns.name = taskorder.category::text;
+
+alter table taskorder drop constraint taskorder_pkey;
+
+alter table taskorder drop column board_id;
+
+alter table taskorder drop column category;
+
+alter table taskorder add primary key (column_id);
+
+drop type categorytype;
+
+commit;
@@ -0,0 +1,6 @@
+-- Lets a column cap the number of tasks in it.
+begin;
+
+alter table board_columns add column wip_limit integer check (wip_limit > 0);
+
+commit;
@@ -0,0 +1,21 @@
+-- Moves task ordering from the taskorder bigint[] arrays to a rank key on
+-- each task. Tasks keep their position inside their column; archived tasks
+-- get a placeholder key that is replaced when they are unarchived.
+begin;
+
+alter table tasks add column rank text collate "C";
+
+update tasks
+set rank = lpad(x.ordinality::text, 6, '0') || 'V'
+from taskorder, unnest(taskorder.value) with ordinality as x(id, ordinality)
+where tasks.id = x.id;
+
+update tasks set rank = 'V' where rank is null;
+
+alter table tasks alter column rank set not null;
+
+create index tasks_column_rank_idx on tasks (column_id, rank) where archived_at is null;
+
+drop table taskorder;
+
+commit;
@@ -0,0 +1,6 @@
+-- Versions boards so changes made against a stale copy can be refused.
+begin;
+
+alter table boards add column version bigint not null default 1;
+
+commit;
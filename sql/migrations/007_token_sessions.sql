@@ -0,0 +1,22 @@
+-- Records the client of every session. Tokens issued before sessions each
+-- become a session of their own, with a random id and no client details.
+begin;
+
+alter table tokens add column session_id text;
+
+update tokens
+set session_id = upper(substr(md5(random()::text || hash::text), 1, 16));
+
+alter table tokens alter column session_id set not null;
+
+alter table tokens
+    add column user_agent text not null default '',
+    add column client_ip text not null default '',
+    add column created_at timestamp(0) with time zone not null default now(),
+    add column last_used_at timestamp(0) with time zone,
+    add column last_user_agent text not null default '',
+    add column last_client_ip text not null default '';
+
+create index tokens_user_session_idx on tokens (user_id, session_id);
+
+commit;
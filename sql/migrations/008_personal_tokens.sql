@@ -0,0 +1,8 @@
+-- Lets users create named personal access tokens limited to scopes.
+begin;
+
+alter table tokens
+    add column name text not null default '',
+    add column scopes text[];
+
+commit;
@@ -0,0 +1,15 @@
+-- Adds the refresh tokens that trade for new access tokens.
+begin;
+
+create table if not exists refresh_tokens (
+    hash bytea primary key,
+    user_id bigint not null references users(id),
+    session_id text not null,
+    expiry timestamp(0) with time zone not null,
+    used_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create index refresh_tokens_user_session_idx on refresh_tokens (user_id, session_id);
+
+commit;
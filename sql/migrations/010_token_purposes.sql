@@ -0,0 +1,6 @@
+-- Tells access tokens apart from single-use tokens such as password resets.
+begin;
+
+alter table tokens add column purpose text not null default 'authentication';
+
+commit;
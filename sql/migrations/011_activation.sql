@@ -0,0 +1,10 @@
+-- Tracks whether users verified their email address. Users who signed up
+-- before verification existed are taken as verified, so they do not become
+-- read-only; only new users start out unverified.
+begin;
+
+alter table users add column activated boolean not null default true;
+
+alter table users alter column activated set default false;
+
+commit;
@@ -0,0 +1,35 @@
+-- Deleting a user deletes everything that belongs to them.
+begin;
+
+alter table tokens
+    drop constraint tokens_user_id_fkey,
+    add constraint tokens_user_id_fkey
+    foreign key (user_id) references users(id) on delete cascade;
+
+alter table refresh_tokens
+    drop constraint refresh_tokens_user_id_fkey,
+    add constraint refresh_tokens_user_id_fkey
+    foreign key (user_id) references users(id) on delete cascade;
+
+alter table boards
+    drop constraint boards_user_id_fkey,
+    add constraint boards_user_id_fkey
+    foreign key (user_id) references users(id) on delete cascade;
+
+alter table board_columns
+    drop constraint board_columns_board_id_fkey,
+    add constraint board_columns_board_id_fkey
+    foreign key (board_id) references boards(id) on delete cascade;
+
+alter table tasks
+    drop constraint tasks_board_id_fkey,
+    add constraint tasks_board_id_fkey
+    foreign key (board_id) references boards(id) on delete cascade,
+    drop constraint tasks_column_id_fkey,
+    add constraint tasks_column_id_fkey
+    foreign key (column_id) references board_columns(id) on delete cascade,
+    drop constraint tasks_user_id_fkey,
+    add constraint tasks_user_id_fkey
+    foreign key (user_id) references users(id) on delete cascade;
+
+commit;
@@ -0,0 +1,16 @@
+-- Adds TOTP two-factor authentication and its recovery codes.
+begin;
+
+alter table users
+    add column totp_secret text,
+    add column totp_enabled boolean not null default false,
+    add column totp_last_step bigint not null default 0;
+
+create table recovery_codes (
+    user_id bigint not null references users(id) on delete cascade,
+    hash bytea not null,
+    used_at timestamp(0) with time zone,
+    primary key (user_id, hash)
+);
+
+commit;
@@ -0,0 +1,11 @@
+-- Records failed logins for the lockouts.
+begin;
+
+create table login_failures (
+    key text primary key,
+    failures integer not null,
+    last_failure_at timestamp(0) with time zone not null,
+    locked_until timestamp(0) with time zone
+);
+
+commit;
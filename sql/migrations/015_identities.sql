@@ -0,0 +1,20 @@
+-- Links users to their OpenID Connect identities and keeps logins in
+-- progress.
+begin;
+
+create table user_identities (
+    user_id bigint not null references users(id) on delete cascade,
+    issuer text not null,
+    subject text not null,
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (issuer, subject)
+);
+
+create table oidc_logins (
+    state_hash bytea primary key,
+    nonce text not null,
+    verifier text not null,
+    expiry timestamp(0) with time zone not null
+);
+
+commit;
@@ -0,0 +1,7 @@
+-- Lets the maintenance jobs find expired tokens without scanning.
+begin;
+
+create index tokens_expiry_idx on tokens (expiry);
+create index refresh_tokens_expiry_idx on refresh_tokens (expiry);
+
+commit;
@@ -0,0 +1,25 @@
+-- Shares boards with members. The user each board belongs to becomes its
+-- owner, and tasks outlive the user who created them.
+begin;
+
+create table board_members (
+    board_id bigint not null references boards(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (board_id, user_id)
+);
+
+create index board_members_user_idx on board_members (user_id);
+
+insert into board_members (board_id, user_id, role)
+select id, user_id, 'owner' from boards;
+
+alter table tasks alter column user_id drop not null;
+
+alter table tasks
+    drop constraint tasks_user_id_fkey,
+    add constraint tasks_user_id_fkey
+    foreign key (user_id) references users(id) on delete set null;
+
+commit;
@@ -0,0 +1,15 @@
+-- Keeps shared boards when the user who created them is deleted. Databases
+-- set up before boards were shared cascade the delete from users to boards,
+-- taking every member's board with the creator; the creator becomes null
+-- instead, and the members who own the board keep it.
+begin;
+
+alter table boards drop constraint boards_user_id_fkey;
+
+alter table boards alter column user_id drop not null;
+
+alter table boards
+    add constraint boards_user_id_fkey
+    foreign key (user_id) references users(id) on delete set null;
+
+commit;
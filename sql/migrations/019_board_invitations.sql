@@ -0,0 +1,17 @@
+-- Adds invitations to boards by email.
+begin;
+
+create table board_invitations (
+    id bigserial primary key,
+    board_id bigint not null references boards(id) on delete cascade,
+    email text not null,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    hash bytea not null unique,
+    invited_by bigint not null references users(id) on delete cascade,
+    expiry timestamp(0) with time zone not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create unique index board_invitations_board_email_idx on board_invitations (board_id, lower(email));
+
+commit;
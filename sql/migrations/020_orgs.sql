@@ -0,0 +1,70 @@
+-- Adds organizations, their teams and the boards they own, and the
+-- board_roles view that combines every way of getting a role on a board.
+begin;
+
+create table orgs (
+    id bigserial primary key,
+    name text not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create table org_members (
+    org_id bigint not null references orgs(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    role text not null check (role in ('owner', 'admin', 'member')),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (org_id, user_id)
+);
+
+create index org_members_user_idx on org_members (user_id);
+
+create table teams (
+    id bigserial primary key,
+    org_id bigint not null references orgs(id) on delete cascade,
+    name text not null,
+    created_at timestamp(0) with time zone not null default now(),
+    unique (org_id, name)
+);
+
+create table team_members (
+    team_id bigint not null references teams(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (team_id, user_id)
+);
+
+create index team_members_user_idx on team_members (user_id);
+
+alter table boards add column org_id bigint references orgs(id) on delete cascade;
+
+create table board_teams (
+    board_id bigint not null references boards(id) on delete cascade,
+    team_id bigint not null references teams(id) on delete cascade,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (board_id, team_id)
+);
+
+create index board_teams_team_idx on board_teams (team_id);
+
+create view board_roles as
+select board_id, user_id,
+    (array['viewer', 'editor', 'owner'])[max(array_position(array['viewer', 'editor', 'owner'], role))] as role
+from (
+    select board_id, user_id, role from board_members
+    union all
+    select board_teams.board_id, team_members.user_id, board_teams.role
+    from board_teams
+    join teams on teams.id = board_teams.team_id
+    join team_members on team_members.team_id = teams.id
+    join org_members on org_members.org_id = teams.org_id
+        and org_members.user_id = team_members.user_id
+    union all
+    select boards.id, org_members.user_id, 'owner'
+    from boards
+    join org_members on org_members.org_id = boards.org_id
+    where org_members.role in ('owner', 'admin')
+) access
+group by board_id, user_id;
+
+commit;
@@ -0,0 +1,13 @@
+-- Lets tasks be assigned to board members.
+begin;
+
+create table task_assignees (
+    task_id bigint not null references tasks(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (task_id, user_id)
+);
+
+create index task_assignees_user_idx on task_assignees (user_id);
+
+commit;
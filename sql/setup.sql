@@ -1,30 +1,200 @@
 create extension if not exists "citext";
-create type categorytype as enum ('TODO', 'IN PROGRESS', 'TESTING', 'DONE');
 
 create table users (
     id bigserial primary key,
     username text not null,
     email citext not null unique,
     password bytea not null,
+    activated boolean not null default false,
+    totp_secret text,
+    totp_enabled boolean not null default false,
+    totp_last_step bigint not null default 0,
     created_at timestamp(0) with time zone not null default now()
 );
 
+create table recovery_codes (
+    user_id bigint not null references users(id) on delete cascade,
+    hash bytea not null,
+    used_at timestamp(0) with time zone,
+    primary key (user_id, hash)
+);
+
 create table if not exists tokens (
     hash bytea primary key,
-    user_id bigint not null references users(id),
+    user_id bigint not null references users(id) on delete cascade,
+    expiry timestamp(0) with time zone not null,
+    purpose text not null default 'authentication',
+    session_id text not null,
+    user_agent text not null default '',
+    client_ip text not null default '',
+    created_at timestamp(0) with time zone not null default now(),
+    last_used_at timestamp(0) with time zone,
+    last_user_agent text not null default '',
+    last_client_ip text not null default '',
+    name text not null default '',
+    scopes text[]
+);
+
+create index tokens_user_session_idx on tokens (user_id, session_id);
+create index tokens_expiry_idx on tokens (expiry);
+
+create table if not exists refresh_tokens (
+    hash bytea primary key,
+    user_id bigint not null references users(id) on delete cascade,
+    session_id text not null,
+    expiry timestamp(0) with time zone not null,
+    used_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create index refresh_tokens_user_session_idx on refresh_tokens (user_id, session_id);
+create index refresh_tokens_expiry_idx on refresh_tokens (expiry);
+
+create table user_identities (
+    user_id bigint not null references users(id) on delete cascade,
+    issuer text not null,
+    subject text not null,
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (issuer, subject)
+);
+
+create table oidc_logins (
+    state_hash bytea primary key,
+    nonce text not null,
+    verifier text not null,
     expiry timestamp(0) with time zone not null
 );
 
+create table login_failures (
+    key text primary key,
+    failures integer not null,
+    last_failure_at timestamp(0) with time zone not null,
+    locked_until timestamp(0) with time zone
+);
+
+create table orgs (
+    id bigserial primary key,
+    name text not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create table org_members (
+    org_id bigint not null references orgs(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    role text not null check (role in ('owner', 'admin', 'member')),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (org_id, user_id)
+);
+
+create index org_members_user_idx on org_members (user_id);
+
+create table teams (
+    id bigserial primary key,
+    org_id bigint not null references orgs(id) on delete cascade,
+    name text not null,
+    created_at timestamp(0) with time zone not null default now(),
+    unique (org_id, name)
+);
+
+create table team_members (
+    team_id bigint not null references teams(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (team_id, user_id)
+);
+
+create index team_members_user_idx on team_members (user_id);
+
+create table boards (
+    id bigserial primary key,
+    user_id bigint references users(id) on delete set null,
+    org_id bigint references orgs(id) on delete cascade,
+    name text not null,
+    version bigint not null default 1,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create table board_members (
+    board_id bigint not null references boards(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (board_id, user_id)
+);
+
+create index board_members_user_idx on board_members (user_id);
+
+create table board_teams (
+    board_id bigint not null references boards(id) on delete cascade,
+    team_id bigint not null references teams(id) on delete cascade,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (board_id, team_id)
+);
+
+create index board_teams_team_idx on board_teams (team_id);
+
+create view board_roles as
+select board_id, user_id,
+    (array['viewer', 'editor', 'owner'])[max(array_position(array['viewer', 'editor', 'owner'], role))] as role
+from (
+    select board_id, user_id, role from board_members
+    union all
+    select board_teams.board_id, team_members.user_id, board_teams.role
+    from board_teams
+    join teams on teams.id = board_teams.team_id
+    join team_members on team_members.team_id = teams.id
+    join org_members on org_members.org_id = teams.org_id
+        and org_members.user_id = team_members.user_id
+    union all
+    select boards.id, org_members.user_id, 'owner'
+    from boards
+    join org_members on org_members.org_id = boards.org_id
+    where org_members.role in ('owner', 'admin')
+) access
+group by board_id, user_id;
+
+create table board_invitations (
+    id bigserial primary key,
+    board_id bigint not null references boards(id) on delete cascade,
+    email text not null,
+    role text not null check (role in ('owner', 'editor', 'viewer')),
+    hash bytea not null unique,
+    invited_by bigint not null references users(id) on delete cascade,
+    expiry timestamp(0) with time zone not null,
+    created_at timestamp(0) with time zone not null default now()
+);
+
+create unique index board_invitations_board_email_idx on board_invitations (board_id, lower(email));
+
+create table board_columns (
+    id bigserial primary key,
+    board_id bigint not null references boards(id) on delete cascade,
+    name text not null,
+    color text not null,
+    position integer not null,
+    wip_limit integer check (wip_limit > 0),
+    created_at timestamp(0) with time zone not null default now()
+);
+
 create table tasks (
     id bigserial primary key,
-    user_id bigint not null references users(id),
+    board_id bigint not null references boards(id) on delete cascade,
+    column_id bigint not null references board_columns(id) on delete cascade,
+    user_id bigint references users(id) on delete set null,
     content text not null,
-    category categorytype not null default 'TODO',
-    created_at timestamp(0) with time zone not null default now()
+    rank text collate "C" not null,
+    created_at timestamp(0) with time zone not null default now(),
+    archived_at timestamp(0) with time zone
 );
 
-create table taskorder (
-    user_id bigint not null references users(id),
-    category categorytype not null,
-    value bigint[]
+create index tasks_column_rank_idx on tasks (column_id, rank) where archived_at is null;
+
+create table task_assignees (
+    task_id bigint not null references tasks(id) on delete cascade,
+    user_id bigint not null references users(id) on delete cascade,
+    created_at timestamp(0) with time zone not null default now(),
+    primary key (task_id, user_id)
 );
+
+create index task_assignees_user_idx on task_assignees (user_id);
@@ -1,5 +1,19 @@
+drop view board_roles;
+drop table oidc_logins;
+drop table user_identities;
+drop table login_failures;
+drop table recovery_codes;
+drop table refresh_tokens;
 drop table tokens;
-drop table taskorder;
+drop table task_assignees;
 drop table tasks;
+drop table board_columns;
+drop table board_invitations;
+drop table board_members;
+drop table board_teams;
+drop table boards;
+drop table team_members;
+drop table teams;
+drop table org_members;
+drop table orgs;
 drop table users;
-drop type categorytype;
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/KishorPokharel/kanban/oidc"
+	"github.com/KishorPokharel/kanban/postgres"
+)
+
+// oidcLoginTTL is how long a user has to finish logging in at the identity
+// provider.
+const oidcLoginTTL = 10 * time.Minute
+
+// oidcStateCookie holds the state of a login in the browser that started
+// it, so a callback URL from someone else's login is refused.
+const oidcStateCookie = "oidc_state"
+
+// handleOIDCLogin sends the user to the identity provider.
+func (app *application) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	if err := app.service.Identity.SaveLoginState(state, nonce, verifier, oidcLoginTTL); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	// SameSite=Lax still sends the cookie on the identity provider's
+	// top-level redirect back to the callback.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(oidcLoginTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, app.sso.AuthCodeURL(state, nonce, challenge), http.StatusFound)
+}
+
+// handleOIDCCallback finishes a login the identity provider sent back and
+// answers like handleUserLogin, asking for a two-factor code if the user set
+// one up.
+func (app *application) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	cookie, err := r.Cookie(oidcStateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(qs.Get("state"))) != 1 {
+		app.errorResponse(w,
+			http.StatusBadRequest,
+			"Invalid or expired login state",
+			errors.New("oidc: state does not match the browser's login"),
+		)
+		return
+	}
+	if e := qs.Get("error"); e != "" {
+		app.errorResponse(w,
+			http.StatusUnauthorized,
+			"Login was cancelled or refused by the identity provider",
+			errors.New("oidc: "+e+": "+qs.Get("error_description")),
+		)
+		return
+	}
+	nonce, verifier, err := app.service.Identity.UseLoginState(qs.Get("state"))
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTokenNotFound):
+			app.errorResponse(w, http.StatusBadRequest, "Invalid or expired login state", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	claims, err := app.sso.Login(r.Context(), qs.Get("code"), verifier, nonce)
+	if err != nil {
+		app.errorResponse(w, http.StatusUnauthorized, "Could not verify the login with the identity provider", err)
+		return
+	}
+	user, err := app.service.Identity.GetOrCreateUser(postgres.Identity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		Username:      ssoUsername(claims),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEmailNotVerified):
+			app.errorResponse(w, http.StatusForbidden, "The identity provider has not verified your email address", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	app.firstFactorResponse(w, r, user)
+}
+
+// ssoUsername picks a username for a user provisioned from claims that fits
+// the rules handleUserRegister applies.
+func ssoUsername(claims *oidc.Claims) string {
+	local, _, _ := strings.Cut(claims.Email, "@")
+	for _, name := range []string{claims.PreferredUsername, claims.Name, local} {
+		name = strings.TrimSpace(name)
+		if len([]rune(name)) >= 3 {
+			if runes := []rune(name); len(runes) > 20 {
+				name = string(runes[:20])
+			}
+			return name
+		}
+	}
+	return "user"
+}
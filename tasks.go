@@ -4,15 +4,42 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/KishorPokharel/kanban/postgres"
 	validator "github.com/go-ozzo/ozzo-validation/v4"
 )
 
+// handleTasksGet returns the board's columns and tasks. The assignee query
+// parameter, a user id or "me", narrows the tasks to the ones assigned to
+// that user.
 func (app *application) handleTasksGet(w http.ResponseWriter, r *http.Request) {
-	user := app.contextGetUser(r)
-	tasks, err := app.service.Task.GetAll(user.ID)
+	qs := r.URL.Query()
+	assigneeID, err := app.readInt(qs, "assignee", 0)
+	if qs.Get("assignee") == "me" {
+		assigneeID, err = int(app.contextGetUser(r).ID), nil
+	}
+	if err == nil && assigneeID < 0 {
+		err = errors.New("must be no less than 0")
+	}
+	if err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors": map[string]any{
+				"assignee": err.Error(),
+			},
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	var tasks []postgres.ColumnTasks
+	if assigneeID > 0 {
+		tasks, err = app.service.Task.GetAllAssigned(board.ID, int64(assigneeID))
+	} else {
+		tasks, err = app.service.Task.GetAll(board.ID)
+	}
 	if err != nil {
 		app.errorResponse(
 			w,
@@ -28,12 +55,15 @@ func (app *application) handleTasksGet(w http.ResponseWriter, r *http.Request) {
 			"tasks": tasks,
 		},
 	}
+	app.setBoardETag(w, board)
 	app.jsonResponse(w, http.StatusOK, out)
 }
 
 func (app *application) handleTaskCreate(w http.ResponseWriter, r *http.Request) {
 	input := struct {
-		Content string `json:"content"`
+		ColumnID         int64  `json:"column_id"`
+		Content          string `json:"content"`
+		OverrideWIPLimit bool   `json:"override_wip_limit"`
 	}{}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		app.errorResponse(
@@ -55,32 +85,360 @@ func (app *application) handleTaskCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	user := app.contextGetUser(r)
+	board := app.contextGetBoard(r)
+	if input.OverrideWIPLimit && board.Role != postgres.RoleOwner {
+		app.errorResponse(
+			w,
+			http.StatusForbidden,
+			"Only the board owner can override WIP limits",
+			errors.New("wip limit override by non owner"),
+		)
+		return
+	}
 	task := &postgres.Task{
-		UserID:  user.ID,
-		Content: input.Content,
+		BoardID:  board.ID,
+		ColumnID: input.ColumnID,
+		UserID:   user.ID,
+		Content:  input.Content,
 	}
-	if err := app.service.Task.Insert(task); err != nil {
-		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
-		return
+	if err := app.service.Task.Insert(board, task, input.OverrideWIPLimit); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrWIPLimitExceeded):
+			app.errorResponse(w, http.StatusConflict, "Column WIP limit exceeded", err)
+			return
+		case errors.Is(err, postgres.ErrColumnNotFound):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"column_id": "column does not exist on this board",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
 	}
 	out := map[string]any{
 		"success": true,
 		"message": "Task added successfully",
 		"data": map[string]any{
 			"id":         task.ID,
+			"column_id":  task.ColumnID,
 			"content":    task.Content,
+			"assignees":  task.Assignees,
 			"created_at": task.CreatedAt,
 		},
 	}
+	app.setBoardETag(w, board)
 	app.jsonResponse(w, http.StatusCreated, out)
 }
 
+func (app *application) handleTaskUpdate(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "task_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+		return
+	}
+	input := struct {
+		Content string `json:"content"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Content, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	task := &postgres.Task{
+		ID:      id,
+		BoardID: board.ID,
+		Content: input.Content,
+	}
+	if err := app.service.Task.Update(board, task); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrTaskNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Task updated successfully",
+		"data": map[string]any{
+			"id":         task.ID,
+			"column_id":  task.ColumnID,
+			"content":    task.Content,
+			"assignees":  task.Assignees,
+			"created_at": task.CreatedAt,
+		},
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTaskDelete(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "task_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if err := app.service.Task.Delete(board, id); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrTaskNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Task deleted successfully",
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTaskArchive(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "task_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if err := app.service.Task.Archive(board, id); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrTaskNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Task archived successfully",
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTaskUnarchive(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "task_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+		return
+	}
+	// The body is optional, since the override is the only thing it
+	// carries.
+	input := struct {
+		OverrideWIPLimit bool `json:"override_wip_limit"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	board := app.contextGetBoard(r)
+	if input.OverrideWIPLimit && board.Role != postgres.RoleOwner {
+		app.errorResponse(
+			w,
+			http.StatusForbidden,
+			"Only the board owner can override WIP limits",
+			errors.New("wip limit override by non owner"),
+		)
+		return
+	}
+	if err := app.service.Task.Unarchive(board, id, input.OverrideWIPLimit); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrWIPLimitExceeded):
+			app.errorResponse(w, http.StatusConflict, "Column WIP limit exceeded", err)
+			return
+		case errors.Is(err, postgres.ErrTaskNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+			return
+		case errors.Is(err, postgres.ErrColumnNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Task unarchived successfully",
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTasksArchivedGet(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	input := struct {
+		Page     int `json:"page"`
+		PageSize int `json:"page_size"`
+	}{}
+	errs := validator.Errors{}
+	var err error
+	if input.Page, err = app.readInt(qs, "page", 1); err != nil {
+		errs["page"] = err
+	}
+	if input.PageSize, err = app.readInt(qs, "page_size", 20); err != nil {
+		errs["page_size"] = err
+	}
+	if len(errs) > 0 {
+		out := map[string]any{
+			"success": false,
+			"errors":  errs,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Page, validator.Min(1), validator.Max(10_000)),
+		validator.Field(&input.PageSize, validator.Min(1), validator.Max(100)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	tasks, total, err := app.service.Task.GetArchived(board.ID, input.Page, input.PageSize)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"tasks": tasks,
+			"metadata": map[string]any{
+				"current_page":  input.Page,
+				"page_size":     input.PageSize,
+				"last_page":     (total + input.PageSize - 1) / input.PageSize,
+				"total_records": total,
+			},
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// handleTaskAssigneesSet replaces the users the task is assigned to.
+func (app *application) handleTaskAssigneesSet(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r, "task_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+		return
+	}
+	input := struct {
+		UserIDs []int64 `json:"user_ids"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.UserIDs, validator.NotNil, validator.Length(0, 20), validator.Each(validator.Min(1))),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	board := app.contextGetBoard(r)
+	assignees, err := app.service.Task.SetAssignees(board, id, input.UserIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrEditConflict):
+			app.editConflictResponse(w, r, err)
+			return
+		case errors.Is(err, postgres.ErrTaskNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Task not found", err)
+			return
+		case errors.Is(err, postgres.ErrAssigneeNotMember):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"user_ids": "tasks can only be assigned to members of the board",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Task assignees updated successfully",
+		"data": map[string]any{
+			"id":        id,
+			"assignees": assignees,
+		},
+	}
+	app.setBoardETag(w, board)
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
 type sortInput struct {
-	TaskID              int64  `json:"task_id"`
-	SourceCategory      string `json:"source_category"`
-	SourceIndex         int64  `json:"source_index"`
-	DestinationCategory string `json:"destination_category"`
-	DestinationIndex    int64  `json:"destination_index"`
+	TaskID              int64 `json:"task_id"`
+	SourceColumnID      int64 `json:"source_column_id"`
+	SourceIndex         int64 `json:"source_index"`
+	DestinationColumnID int64 `json:"destination_column_id"`
+	DestinationIndex    int64 `json:"destination_index"`
+	OverrideWIPLimit    bool  `json:"override_wip_limit"`
 }
 
 func (app *application) handleTaskSort(w http.ResponseWriter, r *http.Request) {
@@ -94,11 +452,21 @@ func (app *application) handleTaskSort(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
+	board := app.contextGetBoard(r)
+	columns, err := app.service.Column.GetAll(board.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	columnIDs := []any{}
+	for _, column := range columns {
+		columnIDs = append(columnIDs, column.ID)
+	}
 	if err := validator.ValidateStruct(&input,
 		validator.Field(&input.TaskID, validator.Min(0)),
-		validator.Field(&input.SourceCategory, validator.Required, validator.In("TODO", "DONE", "IN PROGRESS", "TESTING")),
+		validator.Field(&input.SourceColumnID, validator.Required, validator.In(columnIDs...)),
 		validator.Field(&input.SourceIndex, validator.Min(0)),
-		validator.Field(&input.DestinationCategory, validator.Required, validator.In("TODO", "DONE", "IN PROGRESS", "TESTING")),
+		validator.Field(&input.DestinationColumnID, validator.Required, validator.In(columnIDs...)),
 		validator.Field(&input.DestinationIndex, validator.Min(0)),
 	); err != nil {
 		out := map[string]any{
@@ -108,19 +476,33 @@ func (app *application) handleTaskSort(w http.ResponseWriter, r *http.Request) {
 		app.jsonResponse(w, http.StatusBadRequest, out)
 		return
 	}
-	user := app.contextGetUser(r)
-	if input.SourceCategory == input.DestinationCategory {
+	if input.OverrideWIPLimit && board.Role != postgres.RoleOwner {
+		app.errorResponse(
+			w,
+			http.StatusForbidden,
+			"Only the board owner can override WIP limits",
+			errors.New("wip limit override by non owner"),
+		)
+		return
+	}
+	if input.SourceColumnID == input.DestinationColumnID {
 		if err := app.service.Task.SortTaskInSameCategory(
-			user.ID,
+			board,
 			input.TaskID,
 			input.SourceIndex,
 			input.DestinationIndex,
-			input.DestinationCategory,
+			input.DestinationColumnID,
 		); err != nil {
 			switch {
+			case errors.Is(err, postgres.ErrEditConflict):
+				app.editConflictResponse(w, r, err)
+				return
 			case errors.Is(err, postgres.ErrInvalidData):
 				app.errorResponse(w, http.StatusBadRequest, "invalid data", err)
 				return
+			case errors.Is(err, postgres.ErrColumnNotFound):
+				app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+				return
 			default:
 				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
 				return
@@ -129,21 +511,32 @@ func (app *application) handleTaskSort(w http.ResponseWriter, r *http.Request) {
 		out := map[string]any{
 			"success": true,
 		}
+		app.setBoardETag(w, board)
 		app.jsonResponse(w, http.StatusOK, out)
 		return
 	} else {
 		if err := app.service.Task.SortTaskInDifferentCategory(
-			user.ID,
+			board,
 			input.TaskID,
 			input.SourceIndex,
 			input.DestinationIndex,
-			input.SourceCategory,
-			input.DestinationCategory,
+			input.SourceColumnID,
+			input.DestinationColumnID,
+			input.OverrideWIPLimit,
 		); err != nil {
 			switch {
+			case errors.Is(err, postgres.ErrEditConflict):
+				app.editConflictResponse(w, r, err)
+				return
+			case errors.Is(err, postgres.ErrWIPLimitExceeded):
+				app.errorResponse(w, http.StatusConflict, "Column WIP limit exceeded", err)
+				return
 			case errors.Is(err, postgres.ErrInvalidData):
 				app.errorResponse(w, http.StatusBadRequest, "invalid data", err)
 				return
+			case errors.Is(err, postgres.ErrColumnNotFound):
+				app.errorResponse(w, http.StatusNotFound, "Column not found", err)
+				return
 			default:
 				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
 				return
@@ -152,6 +545,7 @@ func (app *application) handleTaskSort(w http.ResponseWriter, r *http.Request) {
 		out := map[string]any{
 			"success": true,
 		}
+		app.setBoardETag(w, board)
 		app.jsonResponse(w, http.StatusOK, out)
 		return
 	}
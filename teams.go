@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func (app *application) handleTeamsGet(w http.ResponseWriter, r *http.Request) {
+	org := app.contextGetOrg(r)
+	teams, err := app.service.Team.GetAll(org.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"teams": teams,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTeamCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name string `json:"name"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 50)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	org := app.contextGetOrg(r)
+	team := &postgres.Team{
+		OrgID: org.ID,
+		Name:  input.Name,
+	}
+	if err := app.service.Team.Create(team); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrDuplicateTeamName):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"name": "team name already exists",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Team created successfully",
+		"data": map[string]any{
+			"team": team,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+// handleTeamGet returns the team together with its members and the boards
+// it is granted.
+func (app *application) handleTeamGet(w http.ResponseWriter, r *http.Request) {
+	team := app.contextGetTeam(r)
+	members, err := app.service.Team.GetMembers(team.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	boards, err := app.service.Team.GetBoards(team.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"team":    team,
+			"members": members,
+			"boards":  boards,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTeamDelete(w http.ResponseWriter, r *http.Request) {
+	team := app.contextGetTeam(r)
+	if err := app.service.Team.Delete(team.OrgID, team.ID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTeamNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Team not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Team deleted successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTeamMemberAdd(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.readIDParam(r, "user_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	team := app.contextGetTeam(r)
+	if err := app.service.Team.AddMember(team, userID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrOrgMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member added to the team successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTeamMemberDelete(w http.ResponseWriter, r *http.Request) {
+	userID, err := app.readIDParam(r, "user_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+		return
+	}
+	team := app.contextGetTeam(r)
+	if err := app.service.Team.RemoveMember(team.ID, userID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTeamMemberNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Member not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Member removed from the team successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTeamBoardGrant(w http.ResponseWriter, r *http.Request) {
+	boardID, err := app.readIDParam(r, "board_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+		return
+	}
+	input := struct {
+		Role string `json:"role"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Role, validator.Required, validator.In(memberRoles...)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	team := app.contextGetTeam(r)
+	board, err := app.service.Team.GrantBoard(team, boardID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrBoardNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Board access granted successfully",
+		"data": map[string]any{
+			"board": board,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTeamBoardRevoke(w http.ResponseWriter, r *http.Request) {
+	boardID, err := app.readIDParam(r, "board_id")
+	if err != nil {
+		app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+		return
+	}
+	team := app.contextGetTeam(r)
+	if err := app.service.Team.RevokeBoard(team.ID, boardID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrBoardNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Board not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Board access revoked successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
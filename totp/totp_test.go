@@ -0,0 +1,85 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Test vectors from RFC 4226 appendix D.
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+	for i, w := range want {
+		if got := hotp(key, uint64(i), 6); got != w {
+			t.Errorf("counter %d: want %s; got %s", i, w, got)
+		}
+	}
+}
+
+// SHA1 test vectors from RFC 6238 appendix B.
+func TestTOTPVectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		if got := hotp(key, counter(time.Unix(tt.unix, 0)), 8); got != tt.want {
+			t.Errorf("time %d: want %s; got %s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "050471" {
+		t.Fatalf("want 050471; got %s", code)
+	}
+
+	step, ok := Verify(secret, code, now.Add(period*time.Second), 1)
+	if !ok || step != int64(counter(now)) {
+		t.Errorf("code from the previous step should verify, got %d %v", step, ok)
+	}
+	if _, ok := Verify(secret, code, now.Add(2*period*time.Second), 1); ok {
+		t.Error("code outside the skew window should not verify")
+	}
+	if _, ok := Verify(secret, "123", now, 1); ok {
+		t.Error("short code should not verify")
+	}
+	if _, ok := Verify("not base32!", code, now, 1); ok {
+		t.Error("invalid secret should not verify")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err := decodeSecret(secret); err != nil || len(key) != 20 {
+		t.Fatalf("secret %q: got key %v, %v", secret, key, err)
+	}
+	u, err := url.Parse(URI("Kanban", "kishor@gmail.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Query().Get("secret") != secret {
+		t.Errorf("unexpected uri %s", u)
+	}
+}
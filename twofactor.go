@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KishorPokharel/kanban/postgres"
+	"github.com/KishorPokharel/kanban/totp"
+	validator "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	totpIssuer            = "Kanban"
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+func (app *application) handleTwoFactorSetUp(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	secret, err := app.service.User.SetUpTOTP(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTOTPEnabled):
+			app.errorResponse(w, http.StatusConflict, "Two-factor authentication is already enabled", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Add the secret to your authenticator app and confirm with a code",
+		"data": map[string]any{
+			"secret": secret,
+			"uri":    totp.URI(totpIssuer, user.Email, secret),
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTwoFactorConfirm(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Code, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	codes, err := app.service.User.ConfirmTOTP(user.ID, input.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTOTPEnabled):
+			app.errorResponse(w, http.StatusConflict, "Two-factor authentication is already enabled", err)
+			return
+		case errors.Is(err, postgres.ErrTOTPNotSetUp):
+			app.errorResponse(w, http.StatusConflict, "Set up two-factor authentication first", err)
+			return
+		case errors.Is(err, postgres.ErrInvalidTOTPCode):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"code": "invalid code",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Two-factor authentication enabled, store the recovery codes somewhere safe",
+		"data": map[string]any{
+			"recovery_codes": codes,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Password, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user, ok := app.checkCurrentPassword(w, r, input.Password, "password")
+	if !ok {
+		return
+	}
+	if err := app.service.User.DisableTOTP(user.ID); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// handleUserLoginTwoFactor finishes a login for users with two-factor
+// authentication, trading the challenge token handleUserLogin returned and
+// a code for a session.
+func (app *application) handleUserLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.ChallengeToken, validator.Required),
+		validator.Field(&input.Code, validator.When(input.RecoveryCode == "", validator.Required)),
+		validator.Field(&input.RecoveryCode, validator.When(input.Code != "", validator.Empty)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	// Wrong codes count towards the same lockouts as wrong passwords, so a
+	// known password does not buy unlimited guesses at the second factor.
+	challenged, err := app.service.User.GetForChallenge(input.ChallengeToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrUserNotFound):
+			app.errorResponse(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	emailKey := "email:" + strings.ToLower(challenged.Email)
+	ipKey := "ip:" + app.clientIP(r)
+	wait, err := app.service.Throttle.Check(emailKey, ipKey)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.errorResponse(w,
+			http.StatusTooManyRequests,
+			"Too many failed login attempts, try again later",
+			fmt.Errorf("login locked out for %s", wait),
+		)
+		return
+	}
+	user, err := app.service.User.LoginSecondFactor(input.ChallengeToken, input.Code, input.RecoveryCode)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTokenNotFound):
+			app.errorResponse(w, http.StatusUnauthorized, "Invalid or expired challenge token", err)
+			return
+		case errors.Is(err, postgres.ErrInvalidTOTPCode):
+			if err := app.service.Throttle.Fail(emailKey, app.config.emailLockout); err != nil {
+				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+				return
+			}
+			if err := app.service.Throttle.Fail(ipKey, app.config.ipLockout); err != nil {
+				app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+				return
+			}
+			out := map[string]any{
+				"success": false,
+				"message": "Invalid two-factor code, log in again",
+			}
+			app.jsonResponse(w, http.StatusUnauthorized, out)
+			return
+		case errors.Is(err, postgres.ErrTOTPNotSetUp):
+			out := map[string]any{
+				"success": false,
+				"message": "Invalid two-factor code, log in again",
+			}
+			app.jsonResponse(w, http.StatusUnauthorized, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	if err := app.service.Throttle.Reset(emailKey); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	app.loginResponse(w, r, user)
+}
@@ -4,12 +4,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/KishorPokharel/kanban/postgres"
 	validator "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Login hands out a short-lived access token along with a refresh token
+// that is traded for a new pair at /api/users/refresh.
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+	activationTTL    = 3 * 24 * time.Hour
 )
 
 func (app *application) handleUserRegister(w http.ResponseWriter, r *http.Request) {
@@ -17,6 +34,9 @@ func (app *application) handleUserRegister(w http.ResponseWriter, r *http.Reques
 		Username string `json:"username"`
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		// InvitationToken optionally accepts a board invitation sent to
+		// Email as part of signing up.
+		InvitationToken string `json:"invitation_token"`
 	}{}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		app.errorResponse(
@@ -39,6 +59,23 @@ func (app *application) handleUserRegister(w http.ResponseWriter, r *http.Reques
 		app.jsonResponse(w, http.StatusBadRequest, out)
 		return
 	}
+	if input.InvitationToken != "" {
+		invitation, err := app.service.Invitation.Get(input.InvitationToken)
+		if err != nil && !errors.Is(err, postgres.ErrInvitationNotFound) {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		if err != nil || !strings.EqualFold(invitation.Email, input.Email) {
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"invitation_token": "invalid or expired invitation token",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		}
+	}
 	user := &postgres.User{
 		Username: input.Username,
 		Email:    input.Email,
@@ -48,7 +85,7 @@ func (app *application) handleUserRegister(w http.ResponseWriter, r *http.Reques
 		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
 		return
 	}
-	err = app.service.User.Create(user)
+	token, err := app.service.User.Register(user, activationTTL)
 	if err != nil {
 		switch {
 		case errors.Is(err, postgres.ErrDuplicateEmail):
@@ -65,17 +102,165 @@ func (app *application) handleUserRegister(w http.ResponseWriter, r *http.Reques
 			return
 		}
 	}
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi %s,\n\n"+
+				"Thanks for signing up. Use the token below to verify your email address. "+
+				"It expires in %d days.\n\n"+
+				"%s\n",
+			user.Username,
+			int(activationTTL.Hours()/24),
+			token.PlainText,
+		)
+		if err := app.mailer.Send(user.Email, "Verify your email address", body); err != nil {
+			app.logger.Println(err)
+		}
+	})
+	data := map[string]any{
+		"id":         user.ID,
+		"username":   user.Username,
+		"email":      user.Email,
+		"activated":  user.Activated,
+		"created_at": user.CreatedAt,
+	}
+	if input.InvitationToken != "" {
+		// The invitation was checked above, but may have been revoked or
+		// replaced since; the account is created either way.
+		board, err := app.service.Invitation.Accept(input.InvitationToken, user)
+		if err != nil {
+			app.logger.Println(fmt.Errorf("accepting invitation on register: %w", err))
+		} else {
+			data["board"] = board
+			data["activated"] = user.Activated
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "User registered successfully, check your email to verify your address",
+		"data":    data,
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+// handleUserActivationResend mails a new activation token to a user who has
+// not verified their email address yet, replacing the previous one.
+func (app *application) handleUserActivationResend(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Email, validator.Required, is.Email),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	// As with handleUserPasswordForgot, the response does not tell whether
+	// the email is registered or already verified.
+	out := map[string]any{
+		"success": true,
+		"message": "If the email is registered and not yet verified, a new activation token has been sent to it",
+	}
+	user, err := app.service.User.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrUserNotFound):
+			app.jsonResponse(w, http.StatusAccepted, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	if user.Activated {
+		app.jsonResponse(w, http.StatusAccepted, out)
+		return
+	}
+	token, err := app.service.Token.NewActivation(user.ID, activationTTL)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi %s,\n\n"+
+				"Use the token below to verify your email address. It expires in %d days.\n\n"+
+				"%s\n",
+			user.Username,
+			int(activationTTL.Hours()/24),
+			token.PlainText,
+		)
+		if err := app.mailer.Send(user.Email, "Verify your email address", body); err != nil {
+			app.logger.Println(err)
+		}
+	})
+	app.jsonResponse(w, http.StatusAccepted, out)
+}
+
+func (app *application) handleUserActivate(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Token, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user, err := app.service.User.Activate(input.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTokenNotFound):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"token": "invalid or expired activation token",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
 	out := map[string]any{
 		"success": true,
-		"message": "User registered successfully",
+		"message": "Email verified successfully",
 		"data": map[string]any{
 			"id":         user.ID,
 			"username":   user.Username,
 			"email":      user.Email,
+			"activated":  user.Activated,
 			"created_at": user.CreatedAt,
 		},
 	}
-	app.jsonResponse(w, http.StatusCreated, out)
+	app.jsonResponse(w, http.StatusOK, out)
 }
 
 func (app *application) handleUserLogin(w http.ResponseWriter, r *http.Request) {
@@ -103,24 +288,50 @@ func (app *application) handleUserLogin(w http.ResponseWriter, r *http.Request)
 		app.jsonResponse(w, http.StatusBadRequest, out)
 		return
 	}
+	emailKey := "email:" + strings.ToLower(input.Email)
+	ipKey := "ip:" + app.clientIP(r)
+	wait, err := app.service.Throttle.Check(emailKey, ipKey)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.errorResponse(w,
+			http.StatusTooManyRequests,
+			"Too many failed login attempts, try again later",
+			fmt.Errorf("login locked out for %s", wait),
+		)
+		return
+	}
+	// Unknown emails and wrong passwords take the same time and count as
+	// the same kind of failure, so neither reveals who is registered.
+	matches := false
 	user, err := app.service.User.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, postgres.ErrUserNotFound):
-			out := map[string]any{
-				"success": false,
-				"message": "Invalid Credentials",
-			}
-			app.jsonResponse(w, http.StatusUnauthorized, out)
+			postgres.SimulatePasswordCheck(input.Password)
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	} else {
+		matches, err = user.Password.Matches(input.Password)
+		if err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
 			return
 		}
-	}
-	matches, err := user.Password.Matches(input.Password)
-	if err != nil {
-		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
-		return
 	}
 	if !matches {
+		if err := app.service.Throttle.Fail(emailKey, app.config.emailLockout); err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		if err := app.service.Throttle.Fail(ipKey, app.config.ipLockout); err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
 		out := map[string]any{
 			"success": false,
 			"message": "Invalid Credentials",
@@ -128,7 +339,51 @@ func (app *application) handleUserLogin(w http.ResponseWriter, r *http.Request)
 		app.jsonResponse(w, http.StatusUnauthorized, out)
 		return
 	}
-	token, err := app.service.Token.New(user.ID, 24*time.Hour)
+	// With two-factor authentication the email stays throttled until the
+	// second factor checks out in handleUserLoginTwoFactor.
+	if !user.TOTPEnabled {
+		if err := app.service.Throttle.Reset(emailKey); err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	app.firstFactorResponse(w, r, user)
+}
+
+// firstFactorResponse answers a login whose first factor checked out. Users
+// with two-factor authentication get a challenge token to trade for a
+// session through handleUserLoginTwoFactor; everyone else gets a session.
+func (app *application) firstFactorResponse(w http.ResponseWriter, r *http.Request, user *postgres.User) {
+	if user.TOTPEnabled {
+		challenge, err := app.service.Token.NewTwoFactorChallenge(user.ID, twoFactorChallengeTTL)
+		if err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		out := map[string]any{
+			"success": true,
+			"message": "Two-factor code required",
+			"data": map[string]any{
+				"two_factor_required": true,
+				"challenge_token":     challenge.PlainText,
+				"expiry":              challenge.Expiry,
+			},
+		}
+		app.jsonResponse(w, http.StatusOK, out)
+		return
+	}
+	app.loginResponse(w, r, user)
+}
+
+// loginResponse starts a session for user and writes its tokens.
+func (app *application) loginResponse(w http.ResponseWriter, r *http.Request, user *postgres.User) {
+	token, refresh, err := app.service.Token.NewSession(
+		user.ID,
+		accessTokenTTL,
+		refreshTokenTTL,
+		r.UserAgent(),
+		app.clientIP(r),
+	)
 	if err != nil {
 		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
 		return
@@ -136,14 +391,655 @@ func (app *application) handleUserLogin(w http.ResponseWriter, r *http.Request)
 	out := map[string]any{
 		"success": true,
 		"data": map[string]any{
-			"token": token.PlainText,
+			"token":         token.PlainText,
+			"expiry":        token.Expiry,
+			"refresh_token": refresh.PlainText,
 			"user": map[string]any{
 				"id":         user.ID,
 				"username":   user.Username,
 				"email":      user.Email,
+				"activated":  user.Activated,
+				"two_factor": user.TOTPEnabled,
 				"created_at": user.CreatedAt,
 			},
 		},
 	}
 	app.jsonResponse(w, http.StatusOK, out)
 }
+
+func (app *application) handleUserRefresh(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.RefreshToken, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	token, refresh, err := app.service.Token.Refresh(
+		input.RefreshToken,
+		accessTokenTTL,
+		refreshTokenTTL,
+		r.UserAgent(),
+		app.clientIP(r),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTokenNotFound):
+			app.errorResponse(w, http.StatusUnauthorized, "Invalid or expired refresh token", err)
+			return
+		case errors.Is(err, postgres.ErrRefreshTokenReused):
+			app.errorResponse(w, http.StatusUnauthorized, "Refresh token was already used, the session has been revoked", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"token":         token.PlainText,
+			"expiry":        token.Expiry,
+			"refresh_token": refresh.PlainText,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserPasswordForgot(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Email, validator.Required, is.Email),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	// The response is the same whether or not the email is registered, so
+	// the endpoint cannot be used to find out who has an account.
+	out := map[string]any{
+		"success": true,
+		"message": "If the email is registered, password reset instructions have been sent to it",
+	}
+	user, err := app.service.User.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrUserNotFound):
+			app.jsonResponse(w, http.StatusAccepted, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	token, err := app.service.Token.NewPasswordReset(user.ID, passwordResetTTL)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi %s,\n\n"+
+				"Use the token below to set a new password. It expires in %d minutes.\n\n"+
+				"%s\n\n"+
+				"If you did not ask for a password reset you can ignore this email.\n",
+			user.Username,
+			int(passwordResetTTL.Minutes()),
+			token.PlainText,
+		)
+		if err := app.mailer.Send(user.Email, "Reset your password", body); err != nil {
+			app.logger.Println(err)
+		}
+	})
+	app.jsonResponse(w, http.StatusAccepted, out)
+}
+
+func (app *application) handleUserPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Token, validator.Required),
+		validator.Field(&input.Password, validator.Required, validator.Length(10, 30)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := &postgres.User{}
+	if err := user.Password.Set(input.Password); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	if err := app.service.User.ResetPassword(input.Token, user); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTokenNotFound):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"token": "invalid or expired password reset token",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Password reset successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserLogout(w http.ResponseWriter, r *http.Request) {
+	token := app.contextGetToken(r)
+	if err := app.service.Token.Delete(token); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Logged out successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserLogoutAll(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	if err := app.service.Token.DeleteAllForUser(user.ID); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Logged out of all sessions successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserSessionsGet(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	sessions, err := app.service.Token.GetSessions(user.ID, app.contextGetToken(r))
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"sessions": sessions,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserSessionDelete(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	user := app.contextGetUser(r)
+	if err := app.service.Token.DeleteSession(user.ID, params.ByName("session_id")); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrSessionNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Session not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Session revoked successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserTokensGet(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	tokens, err := app.service.Token.GetPersonal(user.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"tokens": tokens,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserTokenCreate(w http.ResponseWriter, r *http.Request) {
+	input := struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}{
+		ExpiresInDays: 90,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Name, validator.Required, validator.Length(1, 100)),
+		validator.Field(&input.Scopes,
+			validator.Required,
+			validator.Each(validator.In(
+				postgres.ScopeTasksRead,
+				postgres.ScopeTasksWrite,
+				postgres.ScopeBoardsAdmin,
+			)),
+		),
+		validator.Field(&input.ExpiresInDays, validator.Min(1), validator.Max(365)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user := app.contextGetUser(r)
+	ttl := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	token, err := app.service.Token.NewPersonal(user.ID, ttl, input.Name, input.Scopes)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Token created successfully",
+		"data": map[string]any{
+			"token":  token.PlainText,
+			"id":     token.SessionID,
+			"name":   token.Name,
+			"scopes": token.Scopes,
+			"expiry": token.Expiry,
+		},
+	}
+	app.jsonResponse(w, http.StatusCreated, out)
+}
+
+func (app *application) handleUserTokenDelete(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	user := app.contextGetUser(r)
+	if err := app.service.Token.DeletePersonal(user.ID, params.ByName("token_id")); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrTokenNotFound):
+			app.errorResponse(w, http.StatusNotFound, "Token not found", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Token revoked successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserPasswordChange(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.CurrentPassword, validator.Required),
+		validator.Field(&input.NewPassword, validator.Required, validator.Length(10, 30)),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user, ok := app.checkCurrentPassword(w, r, input.CurrentPassword, "current_password")
+	if !ok {
+		return
+	}
+	if err := user.Password.Set(input.NewPassword); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	if err := app.service.User.UpdatePassword(user, app.contextGetToken(r)); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Password changed successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+func (app *application) handleUserEmailChange(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Email, validator.Required, is.Email),
+		validator.Field(&input.Password, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user, ok := app.checkCurrentPassword(w, r, input.Password, "password")
+	if !ok {
+		return
+	}
+	user.Email = input.Email
+	if err := app.service.User.UpdateEmail(user, app.contextGetToken(r)); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrDuplicateEmail):
+			out := map[string]any{
+				"success": false,
+				"errors": map[string]any{
+					"email": "email already exists",
+				},
+			}
+			app.jsonResponse(w, http.StatusBadRequest, out)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	token, err := app.service.Token.NewActivation(user.ID, activationTTL)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	app.background(func() {
+		body := fmt.Sprintf(
+			"Hi %s,\n\n"+
+				"Use the token below to verify your new email address. It expires in %d days.\n\n"+
+				"%s\n",
+			user.Username,
+			int(activationTTL.Hours()/24),
+			token.PlainText,
+		)
+		if err := app.mailer.Send(user.Email, "Verify your email address", body); err != nil {
+			app.logger.Println(err)
+		}
+	})
+	out := map[string]any{
+		"success": true,
+		"message": "Email changed successfully, check your email to verify your address",
+		"data": map[string]any{
+			"id":         user.ID,
+			"username":   user.Username,
+			"email":      user.Email,
+			"activated":  user.Activated,
+			"created_at": user.CreatedAt,
+		},
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// checkCurrentPassword loads the authenticated user with their password and
+// checks it against pwd. When it does not match it writes a validation
+// error for field and returns false. Failures count towards the same
+// lockouts as handleUserLogin, so a stolen session cannot be used to guess
+// the password.
+func (app *application) checkCurrentPassword(w http.ResponseWriter, r *http.Request, pwd, field string) (*postgres.User, bool) {
+	user, err := app.service.User.GetByEmail(app.contextGetUser(r).Email)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return nil, false
+	}
+	emailKey := "email:" + strings.ToLower(user.Email)
+	ipKey := "ip:" + app.clientIP(r)
+	wait, err := app.service.Throttle.Check(emailKey, ipKey)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return nil, false
+	}
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		app.errorResponse(w,
+			http.StatusTooManyRequests,
+			"Too many failed password attempts, try again later",
+			fmt.Errorf("password check locked out for %s", wait),
+		)
+		return nil, false
+	}
+	matches, err := user.Password.Matches(pwd)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return nil, false
+	}
+	if !matches {
+		if err := app.service.Throttle.Fail(emailKey, app.config.emailLockout); err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return nil, false
+		}
+		if err := app.service.Throttle.Fail(ipKey, app.config.ipLockout); err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return nil, false
+		}
+		out := map[string]any{
+			"success": false,
+			"errors": map[string]any{
+				field: "incorrect password",
+			},
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return nil, false
+	}
+	if err := app.service.Throttle.Reset(emailKey); err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return nil, false
+	}
+	return user, true
+}
+
+func (app *application) handleUserDelete(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		app.errorResponse(
+			w,
+			http.StatusBadRequest,
+			"Bad request body",
+			fmt.Errorf("error: decoding json: %w", err),
+		)
+		return
+	}
+	if err := validator.ValidateStruct(&input,
+		validator.Field(&input.Password, validator.Required),
+	); err != nil {
+		out := map[string]any{
+			"success": false,
+			"errors":  err,
+		}
+		app.jsonResponse(w, http.StatusBadRequest, out)
+		return
+	}
+	user, ok := app.checkCurrentPassword(w, r, input.Password, "password")
+	if !ok {
+		return
+	}
+	if err := app.service.User.Delete(user.ID); err != nil {
+		switch {
+		case errors.Is(err, postgres.ErrLastOrgOwner):
+			app.errorResponse(w, http.StatusConflict, "Hand over the organizations you are the only owner of first", err)
+			return
+		default:
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+	}
+	out := map[string]any{
+		"success": true,
+		"message": "Account deleted successfully",
+	}
+	app.jsonResponse(w, http.StatusOK, out)
+}
+
+// handleUserExport streams everything stored about the user as one JSON
+// document. Tasks are written as they are read from the database, so the
+// status is already sent if reading fails half way; such errors are only
+// logged and leave the document truncated.
+func (app *application) handleUserExport(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+	boards, err := app.service.Board.GetAll(user.ID)
+	if err != nil {
+		app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+		return
+	}
+	// Boards shared with the user or belonging to an organization hold other
+	// people's data; only the ones they own themselves are exported.
+	owned := []postgres.Board{}
+	columns := []postgres.Column{}
+	for _, board := range boards {
+		if board.Role != postgres.RoleOwner || board.OrgID != nil {
+			continue
+		}
+		owned = append(owned, board)
+		c, err := app.service.Column.GetAll(board.ID)
+		if err != nil {
+			app.errorResponse(w, http.StatusInternalServerError, "Something went wrong", err)
+			return
+		}
+		columns = append(columns, c...)
+	}
+
+	w.Header().Set("content-type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="kanban-export.json"`)
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	write := func(s string) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+	err = func() error {
+		profile := map[string]any{
+			"id":         user.ID,
+			"username":   user.Username,
+			"email":      user.Email,
+			"activated":  user.Activated,
+			"created_at": user.CreatedAt,
+		}
+		if err := write(`{"exported_at":`); err != nil {
+			return err
+		}
+		if err := enc.Encode(time.Now()); err != nil {
+			return err
+		}
+		if err := write(`,"profile":`); err != nil {
+			return err
+		}
+		if err := enc.Encode(profile); err != nil {
+			return err
+		}
+		if err := write(`,"boards":`); err != nil {
+			return err
+		}
+		if err := enc.Encode(owned); err != nil {
+			return err
+		}
+		if err := write(`,"columns":`); err != nil {
+			return err
+		}
+		if err := enc.Encode(columns); err != nil {
+			return err
+		}
+		if err := write(`,"tasks":[`); err != nil {
+			return err
+		}
+		sep := ""
+		err := app.service.Task.Export(user.ID, func(task postgres.ExportedTask) error {
+			if err := write(sep); err != nil {
+				return err
+			}
+			sep = ","
+			return enc.Encode(task)
+		})
+		if err != nil {
+			return err
+		}
+		return write("]}\n")
+	}()
+	if err != nil {
+		app.logger.Println(fmt.Errorf("error: exporting user %d: %w", user.ID, err))
+	}
+}